@@ -43,7 +43,8 @@ var (
 // BasicAuth returns an BasicAuth middleware.
 //
 // For valid credentials it calls the next handler.
-// For missing or invalid credentials, it sends "401 - Unauthorized" response.
+// For missing or invalid credentials, it hands "401 - Unauthorized" to the
+// centralized HTTPErrorHandler.
 func BasicAuth(fn BasicAuthValidator) routerwithmw.MW {
 	c := DefaultBasicAuthConfig
 	c.Validator = fn
@@ -78,7 +79,7 @@ func BasicAuthWithConfig(config BasicAuthConfig) routerwithmw.MW {
 				if err != nil {
 					//panic(fmt.Errorf("fasthttprouter: invalid Authorization=%s", auth))
 
-					c.Error(fasthttp.StatusMessage(fasthttp.StatusUnauthorized), fasthttp.StatusUnauthorized)
+					routerwithmw.HandleError(c, routerwithmw.ErrUnauthorized.WithInternal(err))
 					return
 				}
 				cred := string(b)
@@ -89,7 +90,7 @@ func BasicAuthWithConfig(config BasicAuthConfig) routerwithmw.MW {
 						if err != nil {
 							//panic(fmt.Errorf("fasthttprouter: unable to validate: err=%v", err))
 
-							c.Error(fasthttp.StatusMessage(fasthttp.StatusUnauthorized), fasthttp.StatusUnauthorized)
+							routerwithmw.HandleError(c, routerwithmw.ErrUnauthorized.WithInternal(err))
 							return
 						} else if valid {
 							next(c)
@@ -108,7 +109,7 @@ func BasicAuthWithConfig(config BasicAuthConfig) routerwithmw.MW {
 
 			// Need to return `401` for browsers to pop-up login box.
 			c.Response.Header.Set(routerwithmw.HeaderWWWAuthenticate, basic+" realm="+realm)
-			c.Error(fasthttp.StatusMessage(fasthttp.StatusUnauthorized), fasthttp.StatusUnauthorized)
+			routerwithmw.HandleError(c, routerwithmw.ErrUnauthorized)
 			return
 
 		}
@@ -1,11 +1,11 @@
 package middlewares
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
-	"sync"
+	"io"
+	"strings"
 
-	//fastrouter "github.com/buaazp/fasthttprouter"
 	"fasthttp-mw/routerwithmw"
 	"github.com/labstack/gommon/bytes"
 	"github.com/valyala/fasthttp"
@@ -19,15 +19,49 @@ type (
 
 		// Maximum allowed size for a request body, it can be specified
 		// as `4x` or `4xB`, where x is one of the multiple from K, M, G, T or P.
+		// An empty Limit sets no default limit: only requests matched by
+		// LimitFunc, PathLimits or ContentTypeLimits are limited.
+		// Optional. Default value "".
 		Limit string `json:"limit"`
+
+		// ContentTypeLimits overrides Limit for requests of the given media
+		// types, e.g. "multipart/form-data": "100M". Media types are matched
+		// case-insensitively, without parameters.
+		// Optional. Default value nil.
+		ContentTypeLimits map[string]string `json:"content_type_limits"`
+
+		// PathLimits overrides Limit and ContentTypeLimits for requests whose
+		// path matches one of the rules. Rules are tried in order and the first
+		// match wins.
+		// Optional. Default value nil.
+		PathLimits []PathLimit `json:"path_limits"`
+
+		// LimitFunc returns the limit in bytes for a request, overriding all
+		// other rules. A negative value falls back to the configured rules.
+		// Optional. Default value nil.
+		LimitFunc func(*fasthttp.RequestCtx) int64
+
+		limit      int64 // -1 for no limit
+		typeLimits map[string]int64
+		pathLimits []pathLimit
+	}
+
+	// PathLimit defines a body limit for the paths matching a glob.
+	// See `routerwithmw.SkipPaths()` for the glob syntax.
+	PathLimit struct {
+		Path  string `json:"path"`
+		Limit string `json:"limit"`
+	}
+
+	pathLimit struct {
+		match routerwithmw.Skipper
 		limit int64
 	}
 
 	limitedReader struct {
-		BodyLimitConfig
-		reader  bufio.Reader
-		read    int64
-		context fasthttp.RequestCtx
+		reader io.Reader
+		read   int64
+		limit  int64
 	}
 )
 
@@ -46,10 +80,17 @@ var (
 // header and actual content read, which makes it super secure.
 // Limit can be specified as `4x` or `4xB`, where x is one of the multiple from K, M,
 // G, T or P.
+//
+// When the server has `StreamRequestBody` enabled, bodies with a
+// `Content-Length` are left streaming to the handler: fasthttp never reads
+// past the checked length. Chunked bodies have no length to check and are
+// read through a limited reader, holding at most Limit bytes in memory before
+// the request is rejected; fasthttp releases a request's stream when another
+// one is set, so it cannot be wrapped in place. The server's
+// `MaxRequestBodySize` must be at least the largest configured limit.
 func BodyLimit(limit string) routerwithmw.MW {
 	c := DefaultBodyLimitConfig
 	c.Limit = limit
-	fmt.Println("Calling BodyLimitWithConfig..")
 	return BodyLimitWithConfig(c)
 }
 
@@ -61,12 +102,22 @@ func BodyLimitWithConfig(config BodyLimitConfig) routerwithmw.MW {
 		config.Skipper = DefaultBodyLimitConfig.Skipper
 	}
 
-	limit, err := bytes.Parse(config.Limit)
-	if err != nil {
-		panic(fmt.Errorf("fasthttprouter: invalid body-limit=%s", config.Limit))
+	config.limit = -1
+	if config.Limit != "" {
+		config.limit = parseBodyLimit(config.Limit)
+	}
+	config.typeLimits = make(map[string]int64, len(config.ContentTypeLimits))
+	for t, l := range config.ContentTypeLimits {
+		config.typeLimits[strings.ToLower(t)] = parseBodyLimit(l)
+	}
+	config.pathLimits = make([]pathLimit, len(config.PathLimits))
+	for i, pl := range config.PathLimits {
+		config.pathLimits[i] = pathLimit{
+			match: routerwithmw.SkipPaths(pl.Path),
+			limit: parseBodyLimit(pl.Limit),
+		}
 	}
-	config.limit = limit
-	fmt.Println("Making new handler with body limit = %d", config.limit)
+
 	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 		return func(c *fasthttp.RequestCtx) {
 			if config.Skipper(c) {
@@ -74,26 +125,37 @@ func BodyLimitWithConfig(config BodyLimitConfig) routerwithmw.MW {
 				return
 			}
 
-			req := c.Request
+			req := &c.Request
+			limit := config.limitFor(c)
+			if limit < 0 {
+				next(c)
+				return
+			}
 
 			// Based on content length
-			if len := int64(req.Header.ContentLength()); len > config.limit {
-				fmt.Println("len vs limit: %d vs %d", len, config.limit)
-				c.Error(fasthttp.StatusMessage(fasthttp.StatusRequestEntityTooLarge), fasthttp.StatusRequestEntityTooLarge)
+			if len := int64(req.Header.ContentLength()); len > limit {
+				c.SetConnectionClose()
+				routerwithmw.HandleError(c, routerwithmw.ErrStatusRequestEntityTooLarge)
 				return
 			}
 
-			//TODO: adapting this logic, from for http.Request to
-			//fasthttp.Request, or fasthttp.RequestCtx
 			// Based on content read
-			//	pool := limitedReaderPool(config)
-			//	r := pool.Get().(*limitedReader)
-			//	r.Reset(&req, c)
-			//	defer pool.Put(r)
-			//	req.SetBody(r)
+			if req.IsBodyStream() {
+				if req.Header.ContentLength() < 0 {
+					body, err := io.ReadAll(&limitedReader{reader: c.RequestBodyStream(), limit: limit})
+					if err != nil {
+						c.SetConnectionClose()
+						routerwithmw.HandleError(c, bodyReadError(err))
+						return
+					}
+					req.SetBodyRaw(body)
+				}
+			} else if int64(len(req.Body())) > limit {
+				routerwithmw.HandleError(c, routerwithmw.ErrStatusRequestEntityTooLarge)
+				return
+			}
 
 			next(c)
-			return
 		}
 	}
 }
@@ -102,24 +164,50 @@ func (r *limitedReader) Read(b []byte) (n int, err error) {
 	n, err = r.reader.Read(b)
 	r.read += int64(n)
 	if r.read > r.limit {
-		return n, err
+		return n, routerwithmw.ErrStatusRequestEntityTooLarge
 	}
 	return
 }
 
-//func (r *limitedReader) Close() error {
-//	return r.reader.Close()
-//}
+// limitFor returns the body limit for c, trying LimitFunc, PathLimits and
+// ContentTypeLimits before falling back to Limit. It returns -1 when no rule
+// limits c.
+func (config *BodyLimitConfig) limitFor(c *fasthttp.RequestCtx) int64 {
+	if config.LimitFunc != nil {
+		if l := config.LimitFunc(c); l >= 0 {
+			return l
+		}
+	}
+	for _, pl := range config.pathLimits {
+		if pl.match(c) {
+			return pl.limit
+		}
+	}
+	if len(config.typeLimits) > 0 {
+		ctype := string(c.Request.Header.ContentType())
+		if i := strings.IndexByte(ctype, ';'); i >= 0 {
+			ctype = ctype[:i]
+		}
+		if l, ok := config.typeLimits[strings.ToLower(strings.TrimSpace(ctype))]; ok {
+			return l
+		}
+	}
+	return config.limit
+}
 
-func (r *limitedReader) Reset(reader bufio.Reader, context fasthttp.RequestCtx) {
-	r.reader = reader
-	r.context = context
+func parseBodyLimit(limit string) int64 {
+	l, err := bytes.Parse(limit)
+	if err != nil {
+		panic(fmt.Errorf("fasthttprouter: invalid body-limit=%s", limit))
+	}
+	return l
 }
 
-func limitedReaderPool(c BodyLimitConfig) sync.Pool {
-	return sync.Pool{
-		New: func() interface{} {
-			return &limitedReader{BodyLimitConfig: c}
-		},
+// bodyReadError maps an error from reading the body stream to the error
+// passed to the router's error handler.
+func bodyReadError(err error) error {
+	if errors.Is(err, routerwithmw.ErrStatusRequestEntityTooLarge) {
+		return routerwithmw.ErrStatusRequestEntityTooLarge
 	}
+	return routerwithmw.ErrBadRequest.WithInternal(err)
 }
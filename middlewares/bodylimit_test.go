@@ -0,0 +1,171 @@
+package middlewares
+
+import (
+	"strings"
+	"testing"
+
+	"fasthttp-mw/routerwithmw"
+	"github.com/valyala/fasthttp"
+)
+
+func newBodyLimitClient(t *testing.T, limit string, stream bool) *fasthttp.HostClient {
+	r := routerwithmw.New()
+	r.Use(BodyLimit(limit))
+	r.POST("/", func(c *fasthttp.RequestCtx) {
+		if c.Request.IsBodyStream() {
+			c.Response.Header.Set("X-Streamed", "1")
+		}
+		c.SetBody(c.Request.Body())
+	})
+	return newTestClient(t, &fasthttp.Server{Handler: r.Handler, StreamRequestBody: stream}, false)
+}
+
+func TestBodyLimit(t *testing.T) {
+	small := strings.Repeat("a", 8)
+	large := strings.Repeat("a", 2048)
+
+	for _, stream := range []bool{false, true} {
+		c := newBodyLimitClient(t, "1K", stream)
+		for _, tc := range []struct {
+			name    string
+			body    string
+			chunked bool
+			code    int
+		}{
+			{"small", small, false, fasthttp.StatusOK},
+			{"large", large, false, fasthttp.StatusRequestEntityTooLarge},
+			{"small chunked", small, true, fasthttp.StatusOK},
+			{"large chunked", large, true, fasthttp.StatusRequestEntityTooLarge},
+		} {
+			req := fasthttp.AcquireRequest()
+			req.Header.SetMethod("POST")
+			req.SetRequestURI("http://example.com/")
+			if tc.chunked {
+				req.SetBodyStream(strings.NewReader(tc.body), -1)
+			} else {
+				req.SetBodyString(tc.body)
+			}
+			res := new(fasthttp.Response)
+			if err := c.Do(req, res); err != nil {
+				t.Fatalf("stream=%v %s: %v", stream, tc.name, err)
+			}
+			fasthttp.ReleaseRequest(req)
+
+			if res.StatusCode() != tc.code {
+				t.Errorf("stream=%v %s: status = %d, want %d", stream, tc.name, res.StatusCode(), tc.code)
+			}
+			if tc.code != fasthttp.StatusOK {
+				continue
+			}
+			if string(res.Body()) != tc.body {
+				t.Errorf("stream=%v %s: body = %q, want %q", stream, tc.name, res.Body(), tc.body)
+			}
+			// Bodies with a length keep streaming to the handler, chunked
+			// ones are read by the middleware.
+			streamed := string(res.Header.Peek("X-Streamed")) == "1"
+			if want := stream && !tc.chunked; streamed != want {
+				t.Errorf("stream=%v %s: streamed = %v, want %v", stream, tc.name, streamed, want)
+			}
+		}
+	}
+}
+
+func TestBodyLimitRules(t *testing.T) {
+	r := routerwithmw.New()
+	r.Use(BodyLimitWithConfig(BodyLimitConfig{
+		Limit: "16B",
+		ContentTypeLimits: map[string]string{
+			"text/csv": "1K",
+		},
+		PathLimits: []PathLimit{
+			{Path: "/upload/**", Limit: "64B"},
+		},
+		LimitFunc: func(c *fasthttp.RequestCtx) int64 {
+			if string(c.Request.Header.Peek("X-Unlimited")) == "1" {
+				return 1 << 20
+			}
+			return -1
+		},
+	}))
+	ok := func(c *fasthttp.RequestCtx) {}
+	r.POST("/", ok)
+	r.POST("/upload/*path", ok)
+	c := newTestClient(t, &fasthttp.Server{Handler: r.Handler}, false)
+
+	for _, tc := range []struct {
+		name    string
+		path    string
+		size    int
+		headers map[string]string
+		code    int
+	}{
+		{"default", "/", 16, nil, fasthttp.StatusOK},
+		{"default exceeded", "/", 17, nil, fasthttp.StatusRequestEntityTooLarge},
+		{"content type", "/", 512, map[string]string{"Content-Type": "Text/CSV; charset=utf-8"}, fasthttp.StatusOK},
+		{"path", "/upload/a/b", 64, map[string]string{"Content-Type": "text/csv"}, fasthttp.StatusOK},
+		{"path over content type", "/upload/a/b", 512, map[string]string{"Content-Type": "text/csv"}, fasthttp.StatusRequestEntityTooLarge},
+		{"path exceeded", "/upload/a/b", 65, nil, fasthttp.StatusRequestEntityTooLarge},
+		{"func", "/upload/a", 512, map[string]string{"X-Unlimited": "1"}, fasthttp.StatusOK},
+	} {
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod("POST")
+		req.SetRequestURI("http://example.com" + tc.path)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		req.SetBodyString(strings.Repeat("a", tc.size))
+		res := new(fasthttp.Response)
+		if err := c.Do(req, res); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		fasthttp.ReleaseRequest(req)
+
+		if res.StatusCode() != tc.code {
+			t.Errorf("%s: status = %d, want %d", tc.name, res.StatusCode(), tc.code)
+		}
+	}
+}
+
+func TestBodyLimitWithoutDefault(t *testing.T) {
+	r := routerwithmw.New()
+	r.Use(BodyLimitWithConfig(BodyLimitConfig{
+		ContentTypeLimits: map[string]string{"text/csv": "16B"},
+	}))
+	r.Use(BodyLimitWithConfig(BodyLimitConfig{
+		LimitFunc: func(c *fasthttp.RequestCtx) int64 {
+			if string(c.Request.Header.Peek("X-Small")) == "1" {
+				return 8
+			}
+			return -1
+		},
+	}))
+	r.POST("/", func(c *fasthttp.RequestCtx) {})
+	c := newTestClient(t, &fasthttp.Server{Handler: r.Handler}, false)
+
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		code    int
+	}{
+		{"no rule", nil, fasthttp.StatusOK},
+		{"content type", map[string]string{"Content-Type": "text/csv"}, fasthttp.StatusRequestEntityTooLarge},
+		{"func", map[string]string{"X-Small": "1"}, fasthttp.StatusRequestEntityTooLarge},
+	} {
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod("POST")
+		req.SetRequestURI("http://example.com/")
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		req.SetBodyString(strings.Repeat("a", 4096))
+		res := new(fasthttp.Response)
+		if err := c.Do(req, res); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		fasthttp.ReleaseRequest(req)
+
+		if res.StatusCode() != tc.code {
+			t.Errorf("%s: status = %d, want %d", tc.name, res.StatusCode(), tc.code)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package middlewares
 import (
 	"fasthttp-mw/routerwithmw"
 	"github.com/valyala/fasthttp"
+	"regexp"
 	"strconv"
 	"strings"
 )
@@ -14,11 +15,29 @@ type (
 		Skipper routerwithmw.Skipper
 
 		// AllowOrigin defines a list of origins that may access the resource.
+		// An origin may contain a "*" wildcard matching one or more subdomain
+		// labels, e.g. "https://*.example.com".
 		// Optional. Default value []string{"*"}.
 		AllowOrigins []string `json:"allow_origins"`
 
+		// AllowOriginPatterns defines a list of regular expressions matching
+		// origins that may access the resource, in addition to AllowOrigins.
+		// Patterns must match the whole origin: "p" is compiled as "^(?:p)$",
+		// so `https://.*\.example\.com` does not allow
+		// "https://a.example.com.evil.org".
+		// Optional. Default value []string{}.
+		AllowOriginPatterns []string `json:"allow_origin_patterns"`
+
+		// AllowOriginFunc is a custom function to validate the origin. It takes
+		// the origin and returns true if allowed, or an error which is passed to
+		// the router's error handler. If set, AllowOrigins and
+		// AllowOriginPatterns are ignored.
+		// Optional.
+		AllowOriginFunc func(origin string, c *fasthttp.RequestCtx) (bool, error)
+
 		// AllowMethods defines a list methods allowed when accessing the resource.
-		// This is used in response to a preflight request.
+		// This is used in response to a preflight request. The CORS-safelisted
+		// methods GET, HEAD and POST are always allowed, as by browsers.
 		// Optional. Default value DefaultCORSConfig.AllowMethods.
 		AllowMethods []string `json:"allow_methods"`
 
@@ -30,7 +49,8 @@ type (
 		// AllowCredentials indicates whether or not the response to the request
 		// can be exposed when the credentials flag is true. When used as part of
 		// a response to a preflight request, this indicates whether or not the
-		// actual request can be made using credentials.
+		// actual request can be made using credentials. As "*" is not allowed
+		// with credentials, the request origin is sent back instead.
 		// Optional. Default value false.
 		AllowCredentials bool `json:"allow_credentials"`
 
@@ -43,9 +63,30 @@ type (
 		// can be cached.
 		// Optional. Default value 0.
 		MaxAge int `json:"max_age"`
+
+		// AllowPrivateNetwork indicates whether a preflight request asking for
+		// access to a private network, with the
+		// Access-Control-Request-Private-Network header, is allowed.
+		// See: https://wicg.github.io/private-network-access/
+		// Optional. Default value false.
+		AllowPrivateNetwork bool `json:"allow_private_network"`
+
+		// OptionsPassthrough passes preflight requests to the next handler,
+		// after setting the CORS headers, when they match a registered OPTIONS
+		// route. It requires the middleware to be registered with `Use()`, a
+		// group or a route, not with `Pre()`.
+		// Optional. Default value false.
+		OptionsPassthrough bool `json:"options_passthrough"`
 	}
 )
 
+// Errors
+var (
+	ErrCORSOriginNotAllowed  = routerwithmw.NewHTTPError(fasthttp.StatusForbidden, "CORS origin not allowed")
+	ErrCORSMethodNotAllowed  = routerwithmw.NewHTTPError(fasthttp.StatusForbidden, "CORS method not allowed")
+	ErrCORSHeadersNotAllowed = routerwithmw.NewHTTPError(fasthttp.StatusForbidden, "CORS headers not allowed")
+)
+
 var (
 	// DefaultCORSConfig is the default CORS middleware config.
 	DefaultCORSConfig = CORSConfig{
@@ -68,7 +109,7 @@ func CORSWithConfig(config CORSConfig) routerwithmw.MW {
 	if config.Skipper == nil {
 		config.Skipper = DefaultCORSConfig.Skipper
 	}
-	if len(config.AllowOrigins) == 0 {
+	if len(config.AllowOrigins) == 0 && len(config.AllowOriginPatterns) == 0 {
 		config.AllowOrigins = DefaultCORSConfig.AllowOrigins
 	}
 	if len(config.AllowMethods) == 0 {
@@ -79,6 +120,54 @@ func CORSWithConfig(config CORSConfig) routerwithmw.MW {
 	allowHeaders := strings.Join(config.AllowHeaders, ",")
 	exposeHeaders := strings.Join(config.ExposeHeaders, ",")
 	maxAge := strconv.Itoa(config.MaxAge)
+	allowedHeaders := make(map[string]bool, len(config.AllowHeaders))
+	for _, h := range config.AllowHeaders {
+		allowedHeaders[strings.ToLower(h)] = true
+	}
+
+	var patterns []*regexp.Regexp
+	for _, o := range config.AllowOrigins {
+		if o != "*" && strings.Contains(o, "*") {
+			patterns = append(patterns, wildcardOrigin(o))
+		}
+	}
+	for _, p := range config.AllowOriginPatterns {
+		patterns = append(patterns, regexp.MustCompile("^(?:"+p+")$"))
+	}
+
+	// checkOrigin returns the value of the Access-Control-Allow-Origin
+	// header for origin, or "" if origin is not allowed.
+	checkOrigin := func(origin string, c *fasthttp.RequestCtx) (string, error) {
+		if config.AllowOriginFunc != nil {
+			if origin == "" {
+				return "", nil
+			}
+			ok, err := config.AllowOriginFunc(origin, c)
+			if !ok || err != nil {
+				return "", err
+			}
+			return origin, nil
+		}
+		for _, o := range config.AllowOrigins {
+			if o == "*" {
+				if config.AllowCredentials && origin != "" {
+					return origin, nil
+				}
+				return o, nil
+			}
+			if o == origin {
+				return o, nil
+			}
+		}
+		if origin != "" {
+			for _, re := range patterns {
+				if re.MatchString(origin) {
+					return origin, nil
+				}
+			}
+		}
+		return "", nil
+	}
 
 	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
 		return func(c *fasthttp.RequestCtx) {
@@ -87,28 +176,29 @@ func CORSWithConfig(config CORSConfig) routerwithmw.MW {
 				return
 			}
 
-			req := c.Request
-			res := c.Response
-			origin := req.Header.Peek(routerwithmw.HeaderOrigin)
-			allowOrigin := ""
+			req := &c.Request
+			res := &c.Response
+			origin := string(req.Header.Peek(routerwithmw.HeaderOrigin))
 
 			// Check allowed origins
-			for _, o := range config.AllowOrigins {
-				if o == "*" || o == string(origin) {
-					allowOrigin = o
-					break
-				}
+			allowOrigin, err := checkOrigin(origin, c)
+			if err != nil {
+				routerwithmw.HandleError(c, err)
+				return
 			}
 
 			// Simple request
-			if string(req.Header.Method()) != "OPTIONS" {
+			reqMethod := string(req.Header.Peek(routerwithmw.HeaderAccessControlRequestMethod))
+			if string(req.Header.Method()) != "OPTIONS" || origin == "" || reqMethod == "" {
 				res.Header.Add(routerwithmw.HeaderVary, routerwithmw.HeaderOrigin)
-				res.Header.Set(routerwithmw.HeaderAccessControlAllowOrigin, allowOrigin)
-				if config.AllowCredentials {
-					res.Header.Set(routerwithmw.HeaderAccessControlAllowCredentials, "true")
-				}
-				if exposeHeaders != "" {
-					res.Header.Set(routerwithmw.HeaderAccessControlExposeHeaders, exposeHeaders)
+				if allowOrigin != "" {
+					res.Header.Set(routerwithmw.HeaderAccessControlAllowOrigin, allowOrigin)
+					if config.AllowCredentials {
+						res.Header.Set(routerwithmw.HeaderAccessControlAllowCredentials, "true")
+					}
+					if exposeHeaders != "" {
+						res.Header.Set(routerwithmw.HeaderAccessControlExposeHeaders, exposeHeaders)
+					}
 				}
 				next(c)
 				return
@@ -118,6 +208,24 @@ func CORSWithConfig(config CORSConfig) routerwithmw.MW {
 			res.Header.Add(routerwithmw.HeaderVary, routerwithmw.HeaderOrigin)
 			res.Header.Add(routerwithmw.HeaderVary, routerwithmw.HeaderAccessControlRequestMethod)
 			res.Header.Add(routerwithmw.HeaderVary, routerwithmw.HeaderAccessControlRequestHeaders)
+			if allowOrigin == "" {
+				routerwithmw.HandleError(c, ErrCORSOriginNotAllowed)
+				return
+			}
+			if !safelistedMethod(reqMethod) && !containsString(config.AllowMethods, reqMethod) {
+				routerwithmw.HandleError(c, ErrCORSMethodNotAllowed)
+				return
+			}
+			reqHeaders := string(req.Header.Peek(routerwithmw.HeaderAccessControlRequestHeaders))
+			if allowHeaders != "" {
+				for _, h := range strings.Split(reqHeaders, ",") {
+					if h = strings.TrimSpace(h); h != "" && !allowedHeaders[strings.ToLower(h)] {
+						routerwithmw.HandleError(c, ErrCORSHeadersNotAllowed)
+						return
+					}
+				}
+			}
+
 			res.Header.Set(routerwithmw.HeaderAccessControlAllowOrigin, allowOrigin)
 			res.Header.Set(routerwithmw.HeaderAccessControlAllowMethods, allowMethods)
 			if config.AllowCredentials {
@@ -125,17 +233,47 @@ func CORSWithConfig(config CORSConfig) routerwithmw.MW {
 			}
 			if allowHeaders != "" {
 				res.Header.Set(routerwithmw.HeaderAccessControlAllowHeaders, allowHeaders)
-			} else {
-				h := string(req.Header.Peek(routerwithmw.HeaderAccessControlRequestHeaders))
-				if h != "" {
-					res.Header.Set(routerwithmw.HeaderAccessControlAllowHeaders, h)
-				}
+			} else if reqHeaders != "" {
+				res.Header.Set(routerwithmw.HeaderAccessControlAllowHeaders, reqHeaders)
+			}
+			if config.AllowPrivateNetwork && string(req.Header.Peek(routerwithmw.HeaderAccessControlRequestPrivateNetwork)) == "true" {
+				res.Header.Set(routerwithmw.HeaderAccessControlAllowPrivateNetwork, "true")
 			}
 			if config.MaxAge > 0 {
 				res.Header.Set(routerwithmw.HeaderAccessControlMaxAge, maxAge)
 			}
+			if config.OptionsPassthrough && routerwithmw.RoutePattern(c) != "" {
+				next(c)
+				return
+			}
 			c.SetStatusCode(fasthttp.StatusNoContent)
 			return
 		}
 	}
 }
+
+// safelistedMethod reports whether method is a CORS-safelisted method, which
+// browsers allow whatever Access-Control-Allow-Methods lists.
+// See: https://fetch.spec.whatwg.org/#cors-safelisted-method
+func safelistedMethod(method string) bool {
+	return method == "GET" || method == "HEAD" || method == "POST"
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcardOrigin compiles an origin whose "*" wildcards match one or more
+// subdomain labels.
+func wildcardOrigin(origin string) *regexp.Regexp {
+	parts := strings.Split(origin, "*")
+	for i, p := range parts {
+		parts[i] = regexp.QuoteMeta(p)
+	}
+	return regexp.MustCompile("(?i)^" + strings.Join(parts, `[a-z0-9-]+(?:\.[a-z0-9-]+)*`) + "$")
+}
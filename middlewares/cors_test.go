@@ -0,0 +1,260 @@
+package middlewares
+
+import (
+	"strings"
+	"testing"
+
+	"fasthttp-mw/routerwithmw"
+	"github.com/valyala/fasthttp"
+)
+
+func newCORSClient(t *testing.T, config CORSConfig) *fasthttp.HostClient {
+	r := routerwithmw.New()
+	r.Use(CORSWithConfig(config))
+	r.GET("/", func(c *fasthttp.RequestCtx) {
+		c.SetBodyString("ok")
+	})
+	return newTestClient(t, &fasthttp.Server{Handler: r.Handler}, false)
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	c := newCORSClient(t, CORSConfig{
+		AllowOrigins:     []string{"https://a.example.com"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Total", "X-Page"},
+	})
+
+	res := do(t, c, "GET", "http://example.com/", map[string]string{
+		routerwithmw.HeaderOrigin: "https://a.example.com",
+	})
+	if res.StatusCode() != fasthttp.StatusOK || string(res.Body()) != "ok" {
+		t.Fatalf("response = %d %q, want 200 \"ok\"", res.StatusCode(), res.Body())
+	}
+	for k, want := range map[string]string{
+		routerwithmw.HeaderAccessControlAllowOrigin:      "https://a.example.com",
+		routerwithmw.HeaderAccessControlAllowCredentials: "true",
+		routerwithmw.HeaderAccessControlExposeHeaders:    "X-Total,X-Page",
+		routerwithmw.HeaderVary:                          routerwithmw.HeaderOrigin,
+	} {
+		if got := string(res.Header.Peek(k)); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestCORSSimpleRequestDenied(t *testing.T) {
+	c := newCORSClient(t, CORSConfig{
+		AllowOrigins:     []string{"https://a.example.com"},
+		AllowCredentials: true,
+		ExposeHeaders:    []string{"X-Total"},
+	})
+
+	for name, headers := range map[string]map[string]string{
+		"other origin": {routerwithmw.HeaderOrigin: "https://evil.example.org"},
+		"no origin":    nil,
+	} {
+		res := do(t, c, "GET", "http://example.com/", headers)
+		if res.StatusCode() != fasthttp.StatusOK || string(res.Body()) != "ok" {
+			t.Fatalf("%s: response = %d %q, want 200 \"ok\"", name, res.StatusCode(), res.Body())
+		}
+		for _, k := range []string{
+			routerwithmw.HeaderAccessControlAllowOrigin,
+			routerwithmw.HeaderAccessControlAllowCredentials,
+			routerwithmw.HeaderAccessControlExposeHeaders,
+		} {
+			if got := res.Header.Peek(k); got != nil {
+				t.Errorf("%s: %s = %q, want none", name, k, got)
+			}
+		}
+		if got := string(res.Header.Peek(routerwithmw.HeaderVary)); got != routerwithmw.HeaderOrigin {
+			t.Errorf("%s: %s = %q, want %q", name, routerwithmw.HeaderVary, got, routerwithmw.HeaderOrigin)
+		}
+	}
+}
+
+func TestCORSSimpleRequestWildcard(t *testing.T) {
+	c := newCORSClient(t, CORSConfig{})
+
+	res := do(t, c, "GET", "http://example.com/", map[string]string{
+		routerwithmw.HeaderOrigin: "https://anywhere.example.org",
+	})
+	if got := string(res.Header.Peek(routerwithmw.HeaderAccessControlAllowOrigin)); got != "*" {
+		t.Errorf("%s = %q, want %q", routerwithmw.HeaderAccessControlAllowOrigin, got, "*")
+	}
+	if got := res.Header.Peek(routerwithmw.HeaderAccessControlAllowCredentials); got != nil {
+		t.Errorf("%s = %q, want none", routerwithmw.HeaderAccessControlAllowCredentials, got)
+	}
+}
+
+func TestCORSPreflightRequest(t *testing.T) {
+	c := newCORSClient(t, CORSConfig{
+		AllowOrigins: []string{"https://a.example.com"},
+		AllowMethods: []string{"GET", "PUT"},
+		MaxAge:       600,
+	})
+
+	res := do(t, c, "OPTIONS", "http://example.com/", map[string]string{
+		routerwithmw.HeaderOrigin:                      "https://a.example.com",
+		routerwithmw.HeaderAccessControlRequestMethod:  "PUT",
+		routerwithmw.HeaderAccessControlRequestHeaders: "X-Token",
+	})
+	if res.StatusCode() != fasthttp.StatusNoContent {
+		t.Fatalf("status = %d, want 204", res.StatusCode())
+	}
+	for k, want := range map[string]string{
+		routerwithmw.HeaderAccessControlAllowOrigin:  "https://a.example.com",
+		routerwithmw.HeaderAccessControlAllowMethods: "GET,PUT",
+		routerwithmw.HeaderAccessControlAllowHeaders: "X-Token",
+		routerwithmw.HeaderAccessControlMaxAge:       "600",
+	} {
+		if got := string(res.Header.Peek(k)); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+
+	var vary []string
+	res.Header.VisitAll(func(k, v []byte) {
+		if string(k) == routerwithmw.HeaderVary {
+			vary = append(vary, string(v))
+		}
+	})
+	if len(vary) != 3 {
+		t.Errorf("Vary = %q, want Origin and the two request headers", vary)
+	}
+}
+
+func TestCORSAllowOrigin(t *testing.T) {
+	errOrigin := routerwithmw.NewHTTPError(fasthttp.StatusForbidden, "blocked")
+
+	for _, tc := range []struct {
+		name   string
+		config CORSConfig
+		origin string
+		want   string
+	}{
+		{"wildcard credentials", CORSConfig{AllowCredentials: true}, "https://a.example.com", "https://a.example.com"},
+		{"subdomain", CORSConfig{AllowOrigins: []string{"https://*.example.com"}}, "https://a.b.example.com", "https://a.b.example.com"},
+		{"subdomain apex", CORSConfig{AllowOrigins: []string{"https://*.example.com"}}, "https://example.com", ""},
+		{"subdomain scheme", CORSConfig{AllowOrigins: []string{"https://*.example.com"}}, "http://a.example.com", ""},
+		{"subdomain suffix", CORSConfig{AllowOrigins: []string{"https://*.example.com"}}, "https://a.example.com.evil.org", ""},
+		{"pattern", CORSConfig{AllowOriginPatterns: []string{`^https://pr-\d+\.preview\.example\.com$`}}, "https://pr-42.preview.example.com", "https://pr-42.preview.example.com"},
+		{"pattern mismatch", CORSConfig{AllowOriginPatterns: []string{`^https://pr-\d+\.preview\.example\.com$`}}, "https://a.example.com", ""},
+		{"unanchored pattern", CORSConfig{AllowOriginPatterns: []string{`https://.*\.example\.com`}}, "https://a.example.com", "https://a.example.com"},
+		{"unanchored pattern suffix", CORSConfig{AllowOriginPatterns: []string{`https://.*\.example\.com`}}, "https://a.example.com.evil.org", ""},
+		{"unanchored pattern prefix", CORSConfig{AllowOriginPatterns: []string{`https://.*\.example\.com`}}, "evil://https://a.example.com", ""},
+		{"pattern alternation", CORSConfig{AllowOriginPatterns: []string{`https://a\.example\.com|https://b\.example\.com`}}, "https://b.example.com.evil.org", ""},
+		{"func", CORSConfig{AllowOriginFunc: func(origin string, c *fasthttp.RequestCtx) (bool, error) {
+			return strings.HasSuffix(origin, ".customer.io"), nil
+		}}, "https://acme.customer.io", "https://acme.customer.io"},
+		{"func denied", CORSConfig{AllowOriginFunc: func(origin string, c *fasthttp.RequestCtx) (bool, error) {
+			return false, nil
+		}}, "https://acme.customer.io", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newCORSClient(t, tc.config)
+			res := do(t, c, "GET", "http://example.com/", map[string]string{
+				routerwithmw.HeaderOrigin: tc.origin,
+			})
+			if got := string(res.Header.Peek(routerwithmw.HeaderAccessControlAllowOrigin)); got != tc.want {
+				t.Errorf("%s = %q, want %q", routerwithmw.HeaderAccessControlAllowOrigin, got, tc.want)
+			}
+		})
+	}
+
+	t.Run("func error", func(t *testing.T) {
+		c := newCORSClient(t, CORSConfig{AllowOriginFunc: func(origin string, c *fasthttp.RequestCtx) (bool, error) {
+			return false, errOrigin
+		}})
+		res := do(t, c, "GET", "http://example.com/", map[string]string{
+			routerwithmw.HeaderOrigin: "https://acme.customer.io",
+		})
+		if res.StatusCode() != fasthttp.StatusForbidden {
+			t.Errorf("status = %d, want 403", res.StatusCode())
+		}
+	})
+}
+
+func TestCORSPreflightRejected(t *testing.T) {
+	c := newCORSClient(t, CORSConfig{
+		AllowOrigins: []string{"https://a.example.com"},
+		AllowMethods: []string{"GET", "PUT"},
+		AllowHeaders: []string{"Content-Type", "X-Token"},
+	})
+
+	for _, tc := range []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		code    int
+	}{
+		{"allowed", "https://a.example.com", "PUT", "x-token, content-type", fasthttp.StatusNoContent},
+		{"origin", "https://b.example.com", "PUT", "", fasthttp.StatusForbidden},
+		{"method", "https://a.example.com", "DELETE", "", fasthttp.StatusForbidden},
+		{"safelisted method", "https://a.example.com", "POST", "X-Token", fasthttp.StatusNoContent},
+		{"safelisted method headers", "https://a.example.com", "HEAD", "X-Other", fasthttp.StatusForbidden},
+		{"headers", "https://a.example.com", "PUT", "X-Token, X-Other", fasthttp.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := map[string]string{
+				routerwithmw.HeaderOrigin:                     tc.origin,
+				routerwithmw.HeaderAccessControlRequestMethod: tc.method,
+			}
+			if tc.headers != "" {
+				headers[routerwithmw.HeaderAccessControlRequestHeaders] = tc.headers
+			}
+			res := do(t, c, "OPTIONS", "http://example.com/", headers)
+			if res.StatusCode() != tc.code {
+				t.Errorf("status = %d, want %d", res.StatusCode(), tc.code)
+			}
+			allowOrigin := res.Header.Peek(routerwithmw.HeaderAccessControlAllowOrigin)
+			if tc.code == fasthttp.StatusForbidden && allowOrigin != nil {
+				t.Errorf("%s = %q on a rejected preflight", routerwithmw.HeaderAccessControlAllowOrigin, allowOrigin)
+			}
+		})
+	}
+}
+
+func TestCORSPrivateNetwork(t *testing.T) {
+	headers := map[string]string{
+		routerwithmw.HeaderOrigin:                             "https://a.example.com",
+		routerwithmw.HeaderAccessControlRequestMethod:         "GET",
+		routerwithmw.HeaderAccessControlRequestPrivateNetwork: "true",
+	}
+
+	for _, allow := range []bool{false, true} {
+		c := newCORSClient(t, CORSConfig{AllowPrivateNetwork: allow})
+		res := do(t, c, "OPTIONS", "http://example.com/", headers)
+		got := string(res.Header.Peek(routerwithmw.HeaderAccessControlAllowPrivateNetwork))
+		if want := map[bool]string{true: "true"}[allow]; got != want {
+			t.Errorf("AllowPrivateNetwork=%v: %s = %q, want %q", allow, routerwithmw.HeaderAccessControlAllowPrivateNetwork, got, want)
+		}
+	}
+}
+
+func TestCORSOptionsPassthrough(t *testing.T) {
+	r := routerwithmw.New()
+	r.Use(CORSWithConfig(CORSConfig{OptionsPassthrough: true}))
+	r.GET("/", func(c *fasthttp.RequestCtx) {})
+	r.OPTIONS("/custom", func(c *fasthttp.RequestCtx) {
+		c.SetBodyString("custom")
+	})
+	c := newTestClient(t, &fasthttp.Server{Handler: r.Handler}, false)
+	headers := map[string]string{
+		routerwithmw.HeaderOrigin:                     "https://a.example.com",
+		routerwithmw.HeaderAccessControlRequestMethod: "GET",
+	}
+
+	res := do(t, c, "OPTIONS", "http://example.com/custom", headers)
+	if res.StatusCode() != fasthttp.StatusOK || string(res.Body()) != "custom" {
+		t.Errorf("OPTIONS /custom = %d %q, want 200 \"custom\"", res.StatusCode(), res.Body())
+	}
+	if got := string(res.Header.Peek(routerwithmw.HeaderAccessControlAllowOrigin)); got != "*" {
+		t.Errorf("%s = %q, want %q", routerwithmw.HeaderAccessControlAllowOrigin, got, "*")
+	}
+
+	res = do(t, c, "OPTIONS", "http://example.com/", headers)
+	if res.StatusCode() != fasthttp.StatusNoContent {
+		t.Errorf("OPTIONS / = %d, want 204", res.StatusCode())
+	}
+}
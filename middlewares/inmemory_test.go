@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
+)
+
+// newTestClient runs s on an in-memory listener, over TLS if isTLS is set,
+// and returns a client connected to it.
+func newTestClient(t *testing.T, s *fasthttp.Server, isTLS bool) *fasthttp.HostClient {
+	t.Helper()
+	ln := fasthttputil.NewInmemoryListener()
+	serve := func() error { return s.Serve(ln) }
+	if isTLS {
+		cert, key, err := fasthttp.GenerateTestCertificate("example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AppendCertEmbed(cert, key); err != nil {
+			t.Fatal(err)
+		}
+		serve = func() error { return s.ServeTLS(ln, "", "") }
+	}
+	go serve()
+	t.Cleanup(func() { ln.Close() })
+
+	return &fasthttp.HostClient{
+		Addr:      "example.com",
+		Dial:      func(string) (net.Conn, error) { return ln.Dial() },
+		IsTLS:     isTLS,
+		TLSConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+}
+
+// do sends a request with the given headers and returns the response.
+func do(t *testing.T, c *fasthttp.HostClient, method, uri string, headers map[string]string) *fasthttp.Response {
+	t.Helper()
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	req.Header.SetMethod(method)
+	req.SetRequestURI(uri)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res := new(fasthttp.Response)
+	if err := c.Do(req, res); err != nil {
+		t.Fatalf("%s %s: %v", method, uri, err)
+	}
+	return res
+}
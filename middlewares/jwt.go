@@ -139,8 +139,7 @@ func JWTWithConfig(config JWTConfig) routerwithmw.MW {
 
 			auth, err := extractor(c)
 			if err != nil {
-
-				c.Error(fmt.Sprintf("%s", err.(*routerwithmw.HTTPError).Message), err.(*routerwithmw.HTTPError).Code)
+				routerwithmw.HandleError(c, err)
 				return
 			}
 			token := new(jwt.Token)
@@ -159,7 +158,11 @@ func JWTWithConfig(config JWTConfig) routerwithmw.MW {
 				return
 			}
 
-			c.Error(fmt.Sprintf("%s", ErrJWTInvalid.Message), ErrJWTInvalid.Code)
+			if err != nil {
+				routerwithmw.HandleError(c, ErrJWTInvalid.WithInternal(err))
+				return
+			}
+			routerwithmw.HandleError(c, ErrJWTInvalid)
 
 			return
 		}
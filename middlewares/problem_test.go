@@ -0,0 +1,68 @@
+package middlewares
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"fasthttp-mw/routerwithmw"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMiddlewareFailuresAsProblems(t *testing.T) {
+	ok := func(c *fasthttp.RequestCtx) {}
+	r := routerwithmw.New()
+	r.HTTPErrorHandler = routerwithmw.ProblemHTTPErrorHandler
+	r.GET("/jwt", ok, JWT([]byte("secret")))
+	r.POST("/upload", ok, BodyLimit("8B"))
+	r.GET("/basic", ok, BasicAuth(func(user, pass string, c *fasthttp.RequestCtx) (bool, error) {
+		return false, nil
+	}))
+	c := newTestClient(t, &fasthttp.Server{Handler: r.Handler}, false)
+
+	for _, tc := range []struct {
+		name    string
+		method  string
+		uri     string
+		headers map[string]string
+		body    string
+		status  int
+	}{
+		{"jwt missing", "GET", "/jwt", nil, "", fasthttp.StatusBadRequest},
+		{"jwt invalid", "GET", "/jwt", map[string]string{routerwithmw.HeaderAuthorization: "Bearer nope"}, "", fasthttp.StatusUnauthorized},
+		{"body limit", "POST", "/upload", nil, strings.Repeat("a", 16), fasthttp.StatusRequestEntityTooLarge},
+		{"basic auth", "GET", "/basic", map[string]string{routerwithmw.HeaderAuthorization: "Basic dXNlcjpwYXNz"}, "", fasthttp.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := fasthttp.AcquireRequest()
+			defer fasthttp.ReleaseRequest(req)
+			req.Header.SetMethod(tc.method)
+			req.SetRequestURI("http://example.com" + tc.uri)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			req.SetBodyString(tc.body)
+			res := new(fasthttp.Response)
+			if err := c.Do(req, res); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := string(res.Header.ContentType()); got != routerwithmw.MIMEApplicationProblemJSON {
+				t.Errorf("Content-Type = %q, want %q", got, routerwithmw.MIMEApplicationProblemJSON)
+			}
+			var problem struct {
+				Status int    `json:"status"`
+				Title  string `json:"title"`
+			}
+			if err := json.Unmarshal(res.Body(), &problem); err != nil {
+				t.Fatalf("%v: %s", err, res.Body())
+			}
+			if res.StatusCode() != tc.status || problem.Status != tc.status {
+				t.Errorf("status = %d, problem status = %d, want %d", res.StatusCode(), problem.Status, tc.status)
+			}
+			if problem.Title != fasthttp.StatusMessage(tc.status) {
+				t.Errorf("title = %q, want %q", problem.Title, fasthttp.StatusMessage(tc.status))
+			}
+		})
+	}
+}
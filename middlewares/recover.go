@@ -75,10 +75,15 @@ func RecoverWithConfig(config RecoverConfig) routerwithmw.MW {
 					}
 					stack := make([]byte, config.StackSize)
 					length := runtime.Stack(stack, !config.DisableStackAll)
+					// A panic re-raised by WrapHTTPMiddleware carries the
+					// stack of the goroutine it was raised on.
+					if pe, ok := r.(*routerwithmw.PanicError); ok {
+						length = copy(stack, pe.Stack)
+					}
 					if !config.DisablePrintStack {
 						c.Logger().Printf("[%s] %s %s\n", color.Red("PANIC RECOVER"), err, stack[:length])
 					}
-					c.Error(fasthttp.StatusMessage(fasthttp.StatusInternalServerError)+fmt.Sprintf(": %s", err), fasthttp.StatusInternalServerError)
+					routerwithmw.HandleError(c, routerwithmw.ErrInternalServerError.WithInternal(err))
 				}
 			}()
 			next(c)
@@ -90,8 +90,8 @@ func SecureWithConfig(config SecureConfig) routerwithmw.MW {
 				return
 			}
 
-			req := c.Request
-			res := c.Response
+			req := &c.Request
+			res := &c.Response
 
 			if config.XSSProtection != "" {
 				res.Header.Set(routerwithmw.HeaderXXSSProtection, config.XSSProtection)
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"testing"
+
+	"fasthttp-mw/routerwithmw"
+	"github.com/valyala/fasthttp"
+)
+
+func newSecureClient(t *testing.T, config SecureConfig, isTLS bool) *fasthttp.HostClient {
+	r := routerwithmw.New()
+	r.Use(SecureWithConfig(config))
+	r.GET("/", func(c *fasthttp.RequestCtx) {
+		c.SetBodyString("ok")
+	})
+	return newTestClient(t, &fasthttp.Server{Handler: r.Handler}, isTLS)
+}
+
+func TestSecureDefaultHeaders(t *testing.T) {
+	c := newSecureClient(t, DefaultSecureConfig, false)
+
+	res := do(t, c, "GET", "http://example.com/", nil)
+	for k, want := range map[string]string{
+		routerwithmw.HeaderXXSSProtection:      "1; mode=block",
+		routerwithmw.HeaderXContentTypeOptions: "nosniff",
+		routerwithmw.HeaderXFrameOptions:       "SAMEORIGIN",
+	} {
+		if got := string(res.Header.Peek(k)); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if got := res.Header.Peek(routerwithmw.HeaderStrictTransportSecurity); got != nil {
+		t.Errorf("HSTS sent over plain HTTP: %q", got)
+	}
+}
+
+func TestSecureHSTS(t *testing.T) {
+	config := SecureConfig{
+		HSTSMaxAge:            3600,
+		ContentSecurityPolicy: "default-src 'self'",
+	}
+
+	for _, tc := range []struct {
+		name    string
+		isTLS   bool
+		uri     string
+		headers map[string]string
+	}{
+		{"TLS", true, "https://example.com/", nil},
+		{"X-Forwarded-Proto", false, "http://example.com/", map[string]string{routerwithmw.HeaderXForwardedProto: "https"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newSecureClient(t, config, tc.isTLS)
+			res := do(t, c, "GET", tc.uri, tc.headers)
+			for k, want := range map[string]string{
+				routerwithmw.HeaderStrictTransportSecurity: "max-age=3600; includeSubdomains",
+				routerwithmw.HeaderContentSecurityPolicy:   "default-src 'self'",
+			} {
+				if got := string(res.Header.Peek(k)); got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+
+	config.HSTSExcludeSubdomains = true
+	c := newSecureClient(t, config, true)
+	res := do(t, c, "GET", "https://example.com/", nil)
+	if got := string(res.Header.Peek(routerwithmw.HeaderStrictTransportSecurity)); got != "max-age=3600" {
+		t.Errorf("%s = %q, want %q", routerwithmw.HeaderStrictTransportSecurity, got, "max-age=3600")
+	}
+}
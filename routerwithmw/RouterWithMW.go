@@ -4,15 +4,90 @@ import (
 	"github.com/buaazp/fasthttprouter"
 	"github.com/valyala/fasthttp"
 	//"log"
-	"fmt"
+	"strings"
+	"sync"
 )
 
 type MW = (func(fasthttp.RequestHandler) fasthttp.RequestHandler)
 
 type RouterWithMW struct {
+	// Router is the underlying router. Its options may be set, but handlers
+	// registered on it directly, with r.Router.Handle, bypass the `Use`
+	// middleware, RoutePattern and Routes; register them on r instead.
 	*fasthttprouter.Router
+
+	// HTTPErrorHandler renders the errors reported through HandleError by
+	// handlers and middlewares served by this router.
+	// Default value DefaultHTTPErrorHandler.
+	HTTPErrorHandler HTTPErrorHandler
+
+	// Binder binds requests for Bind and Context.Bind.
+	// Default value &DefaultBinder{}.
+	Binder Binder
+
+	// Validator validates bound requests for Validate, Context.Validate and
+	// Typed.
+	// Default value &TagValidator{}.
+	Validator Validator
+
+	// IPExtractor finds the client IP address for Context.RealIP.
+	// Default value nil, which uses the address of the peer.
+	IPExtractor IPExtractor
+
 	premiddleware []MW
 	middleware    []MW
+	routes        []*Route
+
+	// fallbackMiddleware wraps the responses of the underlying router when no
+	// route matches: redirects, automatic OPTIONS replies, 405 and 404.
+	fallbackMiddleware []MW
+
+	// handler is the compiled premiddleware chain around dispatch and
+	// fallback is the compiled chain around the underlying router, both built
+	// by Freeze.
+	handler  fasthttp.RequestHandler
+	fallback fasthttp.RequestHandler
+	freeze   sync.Once
+	frozen   bool
+}
+
+// Route is a registered route. Name is optional and makes the route
+// addressable by URL; names should be unique, URL uses the first route
+// registered with a name.
+type Route struct {
+	Method string
+	Path   string
+	Name   string
+
+	handle     fasthttp.RequestHandler
+	middleware []MW
+	chain      fasthttp.RequestHandler
+
+	// params are the names of the `:param` and `*catchall` segments of Path.
+	params []string
+
+	// mount is set on the routes registered by Mount.
+	mount *mount
+}
+
+// routeKey is the RequestCtx user value key holding the matched *Route.
+const routeKey = "routerwithmw.route"
+
+// serve is what gets registered in the underlying fasthttprouter tree. It
+// runs after a successful lookup, when route params are already set.
+func (rt *Route) serve(ctx *fasthttp.RequestCtx) {
+	ctx.SetUserValue(routeKey, rt)
+	rt.chain(ctx)
+}
+
+// RoutePattern returns the pattern of the route matched for ctx, e.g.
+// "/users/:id", or "" if the request has not been routed (yet). It is
+// available to `Use` middleware and handlers, not to `Pre` middleware.
+func RoutePattern(ctx *fasthttp.RequestCtx) string {
+	if rt, ok := ctx.UserValue(routeKey).(*Route); ok {
+		return rt.Path
+	}
+	return ""
 }
 
 // Skipper defines a function to skip middleware. Returning true skips processing
@@ -24,43 +99,194 @@ func DefaultSkipper(*fasthttp.RequestCtx) bool {
 	return false
 }
 
+// Pre adds middleware that runs before the route lookup. It may rewrite the
+// request path or method to change which route is matched, but it has no
+// access to route params.
 func (r *RouterWithMW) Pre(premw MW) {
+	r.mustNotBeFrozen("Pre")
 	r.premiddleware = append(r.premiddleware, premw)
 }
 
+// Use adds router-wide middleware that runs after a successful route lookup,
+// with route params and RoutePattern available in the RequestCtx.
 func (r *RouterWithMW) Use(mw MW) {
-	r.premiddleware = append(r.premiddleware, mw)
+	r.mustNotBeFrozen("Use")
+	r.middleware = append(r.middleware, mw)
+}
+
+// UseFallback adds middleware that wraps only the responses sent when no
+// route matches: trailing-slash redirects, automatic OPTIONS replies, 405 and
+// 404. Fallback middleware runs after the router-wide `Use` middleware, which
+// wraps these responses as well.
+func (r *RouterWithMW) UseFallback(mws ...MW) {
+	r.mustNotBeFrozen("UseFallback")
+	r.fallbackMiddleware = append(r.fallbackMiddleware, mws...)
+}
+
+// SetNotFound sets the handler called when no route matches. It gets the
+// router-wide and fallback middleware, followed by mws.
+func (r *RouterWithMW) SetNotFound(handle fasthttp.RequestHandler, mws ...MW) {
+	r.Router.NotFound = applyMiddleware(handle, mws)
+}
+
+// SetMethodNotAllowed sets the handler called when a route matches the path
+// but not the method. The "Allow" header is set before it is called. It gets
+// the router-wide and fallback middleware, followed by mws.
+func (r *RouterWithMW) SetMethodNotAllowed(handle fasthttp.RequestHandler, mws ...MW) {
+	r.Router.MethodNotAllowed = applyMiddleware(handle, mws)
+}
+
+func (r *RouterWithMW) mustNotBeFrozen(method string) {
+	if r.frozen {
+		panic("routerwithmw: " + method + " called after the router was frozen")
+	}
+}
+
+// applyMiddleware wraps h with mws so that mws[0] is the outermost layer.
+func applyMiddleware(h fasthttp.RequestHandler, mws []MW) fasthttp.RequestHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
 }
 
 func New() *RouterWithMW {
-	return &RouterWithMW{Router: fasthttprouter.New(), premiddleware: []MW{}, middleware: []MW{}}
+	r := &RouterWithMW{Router: fasthttprouter.New(), premiddleware: []MW{}, middleware: []MW{}}
+	r.HTTPErrorHandler = DefaultHTTPErrorHandler
+	r.Binder = &DefaultBinder{}
+	r.Validator = &TagValidator{}
+	r.Router.NotFound = notFound
+	r.Router.MethodNotAllowed = methodNotAllowed
+	return r
 }
 
-func (r *RouterWithMW) Handler(ctx *fasthttp.RequestCtx) {
-	path := string(ctx.Path())
-	method := string(ctx.Method())
-
-	//Middleware
-	//handler = foldr apply routedHandler r.middleware
-	//		where routedHandler = r.Lookup(method,path,ctx)
-	handler := func(c *fasthttp.RequestCtx) {
-		if h, _ := r.Lookup(method, path, ctx); h != nil {
-			for i := len(r.middleware) - 1; i >= 0; i-- {
-				h = r.middleware[i](h)
-			}
-			h(ctx)
-		} else {
-			r.Router.Handler(ctx)
+func notFound(ctx *fasthttp.RequestCtx) {
+	HandleError(ctx, ErrNotFound)
+}
+
+func methodNotAllowed(ctx *fasthttp.RequestCtx) {
+	HandleError(ctx, ErrMethodNotAllowed)
+}
+
+// GET is a shortcut for router.Handle("GET", path, handle, mws...)
+func (r *RouterWithMW) GET(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return r.Handle("GET", path, handle, mws...)
+}
+
+// HEAD is a shortcut for router.Handle("HEAD", path, handle, mws...)
+func (r *RouterWithMW) HEAD(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return r.Handle("HEAD", path, handle, mws...)
+}
+
+// OPTIONS is a shortcut for router.Handle("OPTIONS", path, handle, mws...)
+func (r *RouterWithMW) OPTIONS(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return r.Handle("OPTIONS", path, handle, mws...)
+}
+
+// POST is a shortcut for router.Handle("POST", path, handle, mws...)
+func (r *RouterWithMW) POST(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return r.Handle("POST", path, handle, mws...)
+}
+
+// PUT is a shortcut for router.Handle("PUT", path, handle, mws...)
+func (r *RouterWithMW) PUT(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return r.Handle("PUT", path, handle, mws...)
+}
+
+// PATCH is a shortcut for router.Handle("PATCH", path, handle, mws...)
+func (r *RouterWithMW) PATCH(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return r.Handle("PATCH", path, handle, mws...)
+}
+
+// DELETE is a shortcut for router.Handle("DELETE", path, handle, mws...)
+func (r *RouterWithMW) DELETE(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return r.Handle("DELETE", path, handle, mws...)
+}
+
+// Handle registers a new request handle with the given path and method.
+// The optional route-level middleware wraps only this handle and runs after
+// the router-wide middleware.
+// The returned Route can be given a Name, see URL.
+func (r *RouterWithMW) Handle(method, path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	rt := &Route{Method: method, Path: path, handle: handle, middleware: mws, params: paramNames(path)}
+	if r.frozen {
+		r.compile(rt)
+	}
+	r.routes = append(r.routes, rt)
+	r.Router.Handle(method, path, rt.serve)
+	return rt
+}
+
+// paramNames returns the names of the `:param` and `*catchall` segments of
+// the route pattern path.
+func paramNames(path string) []string {
+	var names []string
+	for _, segment := range strings.Split(path, "/") {
+		if len(segment) > 1 && (segment[0] == ':' || segment[0] == '*') {
+			names = append(names, segment[1:])
 		}
-		return
 	}
+	return names
+}
 
-	// Premiddleware
-	//handler' = foldr apply handler r.premiddleware
-	for i := len(r.premiddleware) - 1; i >= 0; i-- {
-		handler = r.premiddleware[i](handler)
+// ServeFiles serves files from the given file system root under path, which
+// must end with "/*filepath", e.g. `router.ServeFiles("/src/*filepath",
+// "/var/www")` serves "/var/www/passwd" for "/src/passwd". Unlike the
+// embedded fasthttprouter.Router's, the files are served through the
+// router's middleware, followed by mws.
+func (r *RouterWithMW) ServeFiles(path, rootPath string, mws ...MW) *Route {
+	if len(path) < 10 || path[len(path)-10:] != "/*filepath" {
+		panic("path must end with /*filepath in path '" + path + "'")
 	}
-	fmt.Println("All middlewares applied!")
-	handler(ctx) //=> premid[0] (premid[1] .... routedHandler...) $ ctx
-	return
+	prefix := path[:len(path)-10]
+	return r.GET(path, fasthttp.FSHandler(rootPath, strings.Count(prefix, "/")), mws...)
+}
+
+// Freeze compiles the middleware chains of every registered route and the
+// premiddleware chain, so that serving a request only costs a lookup and a
+// call. It is called on the first request by Handler. Adding middleware
+// afterwards panics; routes registered afterwards are compiled right away.
+func (r *RouterWithMW) Freeze() {
+	r.freeze.Do(func() {
+		for _, rt := range r.routes {
+			r.compile(rt)
+		}
+		r.fallback = applyMiddleware(applyMiddleware(r.serveFallback, r.fallbackMiddleware), r.middleware)
+		r.handler = applyMiddleware(r.dispatch, r.premiddleware)
+		r.frozen = true
+	})
+}
+
+func (r *RouterWithMW) compile(rt *Route) {
+	rt.chain = applyMiddleware(applyMiddleware(rt.handle, rt.middleware), r.middleware)
+}
+
+// dispatch routes the request to its compiled chain, or to the fallback
+// chain in which the underlying router answers with a redirect, an automatic
+// OPTIONS reply, 405 or 404.
+func (r *RouterWithMW) dispatch(ctx *fasthttp.RequestCtx) {
+	if h, _ := r.Lookup(string(ctx.Method()), string(ctx.Path()), ctx); h != nil {
+		h(ctx)
+		return
+	}
+	r.fallback(ctx)
+}
+
+// serveFallback runs the underlying router for a request no route matched.
+func (r *RouterWithMW) serveFallback(ctx *fasthttp.RequestCtx) {
+	r.Router.Handler(ctx)
+	prefixRedirect(ctx)
+}
+
+// routerOf returns the router serving ctx, or nil outside of a router.
+func routerOf(ctx *fasthttp.RequestCtx) *RouterWithMW {
+	r, _ := ctx.UserValue(routerKey).(*RouterWithMW)
+	return r
+}
+
+// Handler makes the router implement the fasthttp.ListenAndServe interface.
+func (r *RouterWithMW) Handler(ctx *fasthttp.RequestCtx) {
+	r.Freeze()
+	ctx.SetUserValue(routerKey, r)
+	r.handler(ctx) //=> premid[0] (premid[1] .... routedHandler...) $ ctx
 }
@@ -0,0 +1,269 @@
+package routerwithmw
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/valyala/fasthttp"
+	"github.com/vmihailenco/msgpack"
+)
+
+type (
+	// Binder is the interface that wraps the Bind method.
+	Binder interface {
+		Bind(i interface{}, c *fasthttp.RequestCtx) error
+	}
+
+	// DefaultBinder is the default implementation of the Binder interface.
+	DefaultBinder struct{}
+)
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// Bind binds the request into i, a pointer to a struct, with the Binder of
+// the router serving c, or with DefaultBinder outside of a router.
+func Bind(c *fasthttp.RequestCtx, i interface{}) error {
+	if r := routerOf(c); r != nil && r.Binder != nil {
+		return r.Binder.Bind(i, c)
+	}
+	return new(DefaultBinder).Bind(i, c)
+}
+
+// Bind decodes the request body into i according to the Content-Type header,
+// then sets the fields tagged `param:"<name>"`, `query:"<name>"` and
+// `header:"<name>"` from the params of the matched route, query args and
+// request headers, which take precedence over the body.
+//
+// Supported bodies are JSON, XML, msgpack, protobuf (i must implement
+// proto.Message), and `application/x-www-form-urlencoded` and
+// `multipart/form-data` forms, bound to fields tagged `form:"<name>"`.
+// Uploaded files are bound to `*multipart.FileHeader` and
+// `[]*multipart.FileHeader` fields.
+func (b *DefaultBinder) Bind(i interface{}, c *fasthttp.RequestCtx) (err error) {
+	if err = b.bindBody(i, c); err != nil {
+		return
+	}
+
+	params := map[string][]string{}
+	if rt, ok := c.UserValue(routeKey).(*Route); ok {
+		for _, name := range rt.params {
+			if v, ok := c.UserValue(name).(string); ok {
+				params[name] = []string{v}
+			}
+		}
+	}
+	if err = bindData(i, params, "param"); err != nil {
+		return
+	}
+	if err = bindData(i, argsData(c.QueryArgs()), "query"); err != nil {
+		return
+	}
+	headers := map[string][]string{}
+	c.Request.Header.VisitAll(func(k, v []byte) {
+		key := textproto.CanonicalMIMEHeaderKey(string(k))
+		headers[key] = append(headers[key], string(v))
+	})
+	return bindData(i, headers, "header")
+}
+
+func (b *DefaultBinder) bindBody(i interface{}, c *fasthttp.RequestCtx) (err error) {
+	ctype := string(c.Request.Header.ContentType())
+	if j := strings.IndexByte(ctype, ';'); j >= 0 {
+		ctype = ctype[:j]
+	}
+	ctype = strings.ToLower(strings.TrimSpace(ctype))
+
+	if ctype == MIMEMultipartForm {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return NewHTTPError(fasthttp.StatusBadRequest, err.Error()).WithInternal(err)
+		}
+		if err = bindData(i, form.Value, "form"); err != nil {
+			return err
+		}
+		return bindFiles(i, form.File)
+	}
+
+	body := c.PostBody()
+	if len(body) == 0 {
+		return nil
+	}
+	switch ctype {
+	case MIMEApplicationJSON:
+		err = json.Unmarshal(body, i)
+	case MIMEApplicationXML, MIMETextXML:
+		err = xml.Unmarshal(body, i)
+	case MIMEApplicationForm:
+		return bindData(i, argsData(c.PostArgs()), "form")
+	case MIMEApplicationMsgpack:
+		err = msgpack.Unmarshal(body, i)
+	case MIMEApplicationProtobuf:
+		m, ok := i.(proto.Message)
+		if !ok {
+			return ErrUnsupportedMediaType
+		}
+		err = proto.Unmarshal(body, m)
+	default:
+		return ErrUnsupportedMediaType
+	}
+	if err != nil {
+		return NewHTTPError(fasthttp.StatusBadRequest, err.Error()).WithInternal(err)
+	}
+	return nil
+}
+
+func argsData(args *fasthttp.Args) map[string][]string {
+	data := map[string][]string{}
+	args.VisitAll(func(k, v []byte) {
+		data[string(k)] = append(data[string(k)], string(v))
+	})
+	return data
+}
+
+// bindData sets the fields of the struct ptr points to whose tag names a key
+// of data. Header names are matched case-insensitively.
+func bindData(ptr interface{}, data map[string][]string, tag string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	typ, val, ok := structOf(ptr)
+	if !ok {
+		return nil
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		typeField := typ.Field(i)
+		structField := val.Field(i)
+		if !structField.CanSet() {
+			continue
+		}
+		name := typeField.Tag.Get(tag)
+		if typeField.Anonymous && structField.Kind() == reflect.Struct && name == "" {
+			if err := bindData(structField.Addr().Interface(), data, tag); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" || name == "-" {
+			continue
+		}
+		if tag == "header" {
+			name = textproto.CanonicalMIMEHeaderKey(name)
+		}
+		values, ok := data[name]
+		if !ok || len(values) == 0 {
+			continue
+		}
+
+		if structField.Kind() == reflect.Slice && structField.Type().Elem().Kind() != reflect.Uint8 {
+			slice := reflect.MakeSlice(structField.Type(), len(values), len(values))
+			for j, v := range values {
+				if err := setWithProperType(v, slice.Index(j)); err != nil {
+					return bindError(tag, name, err)
+				}
+			}
+			structField.Set(slice)
+			continue
+		}
+		if err := setWithProperType(values[0], structField); err != nil {
+			return bindError(tag, name, err)
+		}
+	}
+	return nil
+}
+
+func bindFiles(ptr interface{}, files map[string][]*multipart.FileHeader) error {
+	if len(files) == 0 {
+		return nil
+	}
+	typ, val, ok := structOf(ptr)
+	if !ok {
+		return nil
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Tag.Get("form")
+		fhs := files[name]
+		if name == "" || len(fhs) == 0 || !val.Field(i).CanSet() {
+			continue
+		}
+		switch typ.Field(i).Type {
+		case fileHeaderType:
+			val.Field(i).Set(reflect.ValueOf(fhs[0]))
+		case fileHeaderSliceType:
+			val.Field(i).Set(reflect.ValueOf(fhs))
+		}
+	}
+	return nil
+}
+
+func structOf(ptr interface{}) (reflect.Type, reflect.Value, bool) {
+	val := reflect.ValueOf(ptr)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return nil, reflect.Value{}, false
+	}
+	return val.Elem().Type(), val.Elem(), true
+}
+
+func bindError(tag, name string, err error) error {
+	return NewHTTPError(fasthttp.StatusBadRequest, fmt.Sprintf("invalid %s %q: %v", tag, name, err)).WithInternal(err)
+}
+
+func setWithProperType(value string, field reflect.Value) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		return setWithProperType(value, field.Elem())
+	}
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		if value == "" {
+			value = "false"
+		}
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(v)
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(v)
+	default:
+		return errors.New("unsupported type " + field.Type().String())
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package routerwithmw
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/valyala/fasthttp"
+	"github.com/vmihailenco/msgpack"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type bindUser struct {
+	ID    int       `json:"id" xml:"id" form:"id" msgpack:"id" param:"id"`
+	Name  string    `json:"name" xml:"name" form:"name" msgpack:"name" query:"name"`
+	Tags  []string  `json:"tags" xml:"tags" form:"tags" msgpack:"tags" query:"tag"`
+	Token string    `json:"token" xml:"token" form:"token" msgpack:"token" header:"x-token"`
+	Age   *int      `json:"age" xml:"age" form:"age" msgpack:"age"`
+	Since time.Time `form:"since" query:"since"`
+	Admin string    `param:"admin"`
+}
+
+// bindRequest binds a request to POST /users/:id into a new value of the
+// type of target and returns it with the error of Bind.
+func bindRequest(t *testing.T, target interface{}, uri, ctype string, body []byte, headers map[string]string) (interface{}, error) {
+	t.Helper()
+	var bindErr error
+	r := New()
+	r.Use(func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(c *fasthttp.RequestCtx) {
+			c.SetUserValue("admin", "true")
+			next(c)
+		}
+	})
+	v := reflect.New(reflect.TypeOf(target).Elem()).Interface()
+	r.POST("/users/:id", func(c *fasthttp.RequestCtx) {
+		bindErr = Bind(c, v)
+	})
+
+	var req fasthttp.Request
+	req.Header.SetMethod("POST")
+	req.SetRequestURI(uri)
+	if ctype != "" {
+		req.Header.SetContentType(ctype)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.SetBody(body)
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&req, nil, discardLogger{})
+	r.Handler(ctx)
+	return v, bindErr
+}
+
+func intPtr(i int) *int { return &i }
+
+func TestBindBody(t *testing.T) {
+	msgpackBody, err := msgpack.Marshal(map[string]interface{}{"name": "ann", "tags": []string{"a", "b"}, "age": 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	mw.WriteField("name", "ann")
+	mw.WriteField("tags", "a")
+	mw.WriteField("tags", "b")
+	mw.WriteField("age", "30")
+	mw.Close()
+
+	want := &bindUser{ID: 7, Name: "ann", Tags: []string{"a", "b"}, Age: intPtr(30)}
+	for _, tc := range []struct {
+		name  string
+		ctype string
+		body  []byte
+	}{
+		{"json", MIMEApplicationJSON, []byte(`{"name":"ann","tags":["a","b"],"age":30}`)},
+		{"json charset", "Application/JSON; charset=utf-8", []byte(`{"name":"ann","tags":["a","b"],"age":30}`)},
+		{"xml", MIMEApplicationXML, []byte(`<user><name>ann</name><tags>a</tags><tags>b</tags><age>30</age></user>`)},
+		{"text xml", MIMETextXMLCharsetUTF8, []byte(`<user><name>ann</name><tags>a</tags><tags>b</tags><age>30</age></user>`)},
+		{"form", MIMEApplicationForm, []byte(`name=ann&tags=a&tags=b&age=30`)},
+		{"multipart", mw.FormDataContentType(), multipartBody.Bytes()},
+		{"msgpack", MIMEApplicationMsgpack, msgpackBody},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := bindRequest(t, &bindUser{}, "/users/7", tc.ctype, tc.body, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestBindProtobuf(t *testing.T) {
+	body, err := proto.Marshal(wrapperspb.String("ann"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bindRequest(t, &wrapperspb.StringValue{}, "/users/7", MIMEApplicationProtobuf, body, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := got.(*wrapperspb.StringValue).GetValue(); v != "ann" {
+		t.Errorf("got %q, want %q", v, "ann")
+	}
+
+	_, err = bindRequest(t, &bindUser{}, "/users/7", MIMEApplicationProtobuf, body, nil)
+	if !errors.Is(err, ErrUnsupportedMediaType) {
+		t.Errorf("protobuf into a non-message: err = %v, want 415", err)
+	}
+}
+
+func TestBindMultipartFiles(t *testing.T) {
+	type upload struct {
+		Avatar *multipart.FileHeader   `form:"avatar"`
+		Docs   []*multipart.FileHeader `form:"docs"`
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, f := range []struct{ field, name string }{{"avatar", "me.png"}, {"docs", "a.txt"}, {"docs", "b.txt"}} {
+		w, _ := mw.CreateFormFile(f.field, f.name)
+		w.Write([]byte("data"))
+	}
+	mw.Close()
+
+	got, err := bindRequest(t, &upload{}, "/users/7", mw.FormDataContentType(), body.Bytes(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := got.(*upload)
+	if u.Avatar == nil || u.Avatar.Filename != "me.png" {
+		t.Errorf("Avatar = %+v", u.Avatar)
+	}
+	if len(u.Docs) != 2 || u.Docs[0].Filename != "a.txt" || u.Docs[1].Filename != "b.txt" {
+		t.Errorf("Docs = %+v", u.Docs)
+	}
+}
+
+func TestBindPrecedence(t *testing.T) {
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	got, err := bindRequest(t, &bindUser{}, "/users/7?name=query&tag=x&tag=y&since="+since.Format(time.RFC3339),
+		MIMEApplicationJSON, []byte(`{"id":1,"name":"body","tags":["z"],"token":"body"}`),
+		map[string]string{"X-Token": "header"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &bindUser{ID: 7, Name: "query", Tags: []string{"x", "y"}, Token: "header", Since: since}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestBindOnlyRouteParams(t *testing.T) {
+	got, err := bindRequest(t, &bindUser{}, "/users/7", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := got.(*bindUser); u.Admin != "" || u.ID != 7 {
+		t.Errorf("got %+v, want only the route param id bound", u)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		uri   string
+		ctype string
+		body  string
+		code  int
+	}{
+		{"unsupported media type", "/users/7", MIMETextPlain, "hi", http.StatusUnsupportedMediaType},
+		{"missing content type", "/users/7", "", "hi", http.StatusUnsupportedMediaType},
+		{"malformed json", "/users/7", MIMEApplicationJSON, `{"name":`, http.StatusBadRequest},
+		{"malformed xml", "/users/7", MIMEApplicationXML, `<user>`, http.StatusBadRequest},
+		{"bad param", "/users/x", "", "", http.StatusBadRequest},
+		{"bad query", "/users/7?since=yesterday", "", "", http.StatusBadRequest},
+		{"bad form value", "/users/7", MIMEApplicationForm, "age=old", http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := bindRequest(t, &bindUser{}, tc.uri, tc.ctype, []byte(tc.body), nil)
+			var he *HTTPError
+			if !errors.As(err, &he) || he.Code != tc.code {
+				t.Fatalf("err = %v, want HTTP %d", err, tc.code)
+			}
+			if tc.code == http.StatusBadRequest && he.Inner == nil {
+				t.Error("the cause is not kept as Inner")
+			}
+		})
+	}
+}
+
+func TestBindEmptyBody(t *testing.T) {
+	got, err := bindRequest(t, &bindUser{}, "/users/7", MIMEApplicationJSON, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := got.(*bindUser); u.ID != 7 || u.Name != "" {
+		t.Errorf("got %+v", u)
+	}
+}
@@ -0,0 +1,143 @@
+package routerwithmw
+
+import (
+	"encoding/json"
+	"encoding/xml"
+
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// Context wraps a *fasthttp.RequestCtx with the request and response
+	// helpers of Echo's Context.
+	Context struct {
+		*fasthttp.RequestCtx
+	}
+
+	// ContextHandlerFunc is a HandlerFunc taking a *Context.
+	ContextHandlerFunc func(*Context) error
+
+	// ContextMW is a middleware working on ContextHandlerFuncs.
+	ContextMW = (func(ContextHandlerFunc) ContextHandlerFunc)
+)
+
+// NewContext wraps ctx in a Context.
+func NewContext(ctx *fasthttp.RequestCtx) *Context {
+	return &Context{RequestCtx: ctx}
+}
+
+// FromContextHandler adapts h to a fasthttp.RequestHandler. A returned error
+// is handed to the centralized HTTPErrorHandler.
+func FromContextHandler(h ContextHandlerFunc) fasthttp.RequestHandler {
+	return FromHandlerFunc(func(c *fasthttp.RequestCtx) error {
+		return h(NewContext(c))
+	})
+}
+
+// FromContextMW adapts m to a MW. See `FromMWE()`.
+func FromContextMW(m ContextMW) MW {
+	return FromMWE(func(next HandlerFunc) HandlerFunc {
+		h := m(func(c *Context) error {
+			return next(c.RequestCtx)
+		})
+		return func(c *fasthttp.RequestCtx) error {
+			return h(NewContext(c))
+		}
+	})
+}
+
+// Param returns the value of the route param name, or "" if there is none.
+func (c *Context) Param(name string) string {
+	v, _ := c.UserValue(name).(string)
+	return v
+}
+
+// QueryParam returns the first value of the query arg name.
+func (c *Context) QueryParam(name string) string {
+	return string(c.QueryArgs().Peek(name))
+}
+
+// Bind binds the request into i, see `Bind()`.
+func (c *Context) Bind(i interface{}) error {
+	return Bind(c.RequestCtx, i)
+}
+
+// JSON sends a JSON response with status code.
+func (c *Context) JSON(code int, i interface{}) error {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	return c.Blob(code, MIMEApplicationJSONCharsetUTF8, b)
+}
+
+// XML sends an XML response with status code.
+func (c *Context) XML(code int, i interface{}) error {
+	b, err := xml.Marshal(i)
+	if err != nil {
+		return err
+	}
+	return c.Blob(code, MIMEApplicationXMLCharsetUTF8, append([]byte(xml.Header), b...))
+}
+
+// String sends a string response with status code.
+func (c *Context) String(code int, s string) error {
+	return c.Blob(code, MIMETextPlainCharsetUTF8, []byte(s))
+}
+
+// Blob sends a blob response with status code and content type.
+func (c *Context) Blob(code int, contentType string, b []byte) error {
+	c.SetStatusCode(code)
+	c.SetContentType(contentType)
+	c.SetBody(b)
+	return nil
+}
+
+// NoContent sends a response with no body and a status code.
+func (c *Context) NoContent(code int) error {
+	c.SetStatusCode(code)
+	c.ResetBody()
+	return nil
+}
+
+// Redirect redirects the request to url with status code, one of 301, 302,
+// 303, 307 or 308.
+func (c *Context) Redirect(code int, url string) error {
+	switch code {
+	case fasthttp.StatusMovedPermanently, fasthttp.StatusFound, fasthttp.StatusSeeOther,
+		fasthttp.StatusTemporaryRedirect, fasthttp.StatusPermanentRedirect:
+	default:
+		return ErrInvalidRedirectCode
+	}
+	c.RequestCtx.Redirect(url, code)
+	return nil
+}
+
+// Get retrieves data from the context.
+func (c *Context) Get(key string) interface{} {
+	return c.UserValue(key)
+}
+
+// Set saves data in the context.
+func (c *Context) Set(key string, val interface{}) {
+	c.SetUserValue(key, val)
+}
+
+// GetValue retrieves data of type T from the context. The second result is
+// false if key is not set or holds a value of another type.
+func GetValue[T any](c *Context, key string) (T, bool) {
+	v, ok := c.UserValue(key).(T)
+	return v, ok
+}
+
+// RealIP returns the client's IP address, as found by the IPExtractor of the
+// router serving the request. Without an IPExtractor, it is the address of
+// the peer: proxy headers such as `X-Forwarded-For` are set by clients as
+// well and are only trusted with ExtractIPFromXFFHeader or
+// ExtractIPFromRealIPHeader.
+func (c *Context) RealIP() string {
+	if r := routerOf(c.RequestCtx); r != nil && r.IPExtractor != nil {
+		return r.IPExtractor(c.RequestCtx)
+	}
+	return c.RemoteIP().String()
+}
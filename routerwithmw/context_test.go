@@ -0,0 +1,148 @@
+package routerwithmw
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestContextRedirect(t *testing.T) {
+	for code, valid := range map[int]bool{
+		fasthttp.StatusMovedPermanently:  true,
+		fasthttp.StatusFound:             true,
+		fasthttp.StatusSeeOther:          true,
+		fasthttp.StatusTemporaryRedirect: true,
+		fasthttp.StatusPermanentRedirect: true,
+		fasthttp.StatusMultipleChoices:   false,
+		fasthttp.StatusNotModified:       false,
+		fasthttp.StatusOK:                false,
+		fasthttp.StatusBadRequest:        false,
+	} {
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Request.SetRequestURI("http://example.com/old")
+		err := NewContext(ctx).Redirect(code, "/new")
+		if !valid {
+			if !errors.Is(err, ErrInvalidRedirectCode) {
+				t.Errorf("Redirect(%d) = %v, want ErrInvalidRedirectCode", code, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Redirect(%d) = %v", code, err)
+		}
+		if ctx.Response.StatusCode() != code {
+			t.Errorf("Redirect(%d): status = %d", code, ctx.Response.StatusCode())
+		}
+		if got := string(ctx.Response.Header.Peek(HeaderLocation)); got != "http://example.com/new" {
+			t.Errorf("Redirect(%d): Location = %q", code, got)
+		}
+	}
+}
+
+func TestContextNoContent(t *testing.T) {
+	ctx := new(fasthttp.RequestCtx)
+	c := NewContext(ctx)
+	c.SetBodyString("stale")
+	if err := c.NoContent(fasthttp.StatusNoContent); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Response.StatusCode() != fasthttp.StatusNoContent || len(ctx.Response.Body()) != 0 {
+		t.Errorf("response = %d %q, want 204 with no body", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+}
+
+func TestContextValues(t *testing.T) {
+	r := New()
+	r.GET("/users/:id", FromContextHandler(func(c *Context) error {
+		c.Set("count", 3)
+		if got := c.Param("id"); got != "42" {
+			t.Errorf("Param(id) = %q, want 42", got)
+		}
+		if got := c.Param("missing"); got != "" {
+			t.Errorf("Param(missing) = %q, want \"\"", got)
+		}
+		if got := c.QueryParam("q"); got != "go" {
+			t.Errorf("QueryParam(q) = %q, want go", got)
+		}
+		if n, ok := GetValue[int](c, "count"); !ok || n != 3 {
+			t.Errorf("GetValue[int](count) = %v, %v", n, ok)
+		}
+		if _, ok := GetValue[string](c, "count"); ok {
+			t.Error("GetValue[string](count) succeeded on an int")
+		}
+		if _, ok := GetValue[int](c, "unset"); ok {
+			t.Error("GetValue[int](unset) succeeded")
+		}
+		return c.String(fasthttp.StatusOK, "ok")
+	}))
+
+	if ctx := serve(r, "GET", "/users/42?q=go"); string(ctx.Response.Body()) != "ok" {
+		t.Errorf("body = %q", ctx.Response.Body())
+	}
+}
+
+func TestContextRealIP(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		extractor IPExtractor
+		remote    string
+		xff       string
+		realIP    string
+		want      string
+	}{
+		{"default ignores headers", nil, "203.0.113.7", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"direct", ExtractIPDirect(), "203.0.113.7", "198.51.100.1", "", "203.0.113.7"},
+		{"xff untrusted peer", ExtractIPFromXFFHeader("10.0.0.0/8"), "203.0.113.7", "198.51.100.1", "", "203.0.113.7"},
+		{"xff trusted peer", ExtractIPFromXFFHeader("10.0.0.0/8"), "10.0.0.1", "198.51.100.1", "", "198.51.100.1"},
+		{"xff spoofed hops", ExtractIPFromXFFHeader("10.0.0.0/8"), "10.0.0.1", "1.2.3.4, 198.51.100.1, 10.0.0.2", "", "198.51.100.1"},
+		{"xff single proxy", ExtractIPFromXFFHeader("10.0.0.1"), "10.0.0.1", "198.51.100.1", "", "198.51.100.1"},
+		{"xff garbage", ExtractIPFromXFFHeader("10.0.0.0/8"), "10.0.0.1", "not-an-ip", "", "10.0.0.1"},
+		{"real ip trusted", ExtractIPFromRealIPHeader("10.0.0.0/8"), "10.0.0.1", "", "198.51.100.2", "198.51.100.2"},
+		{"real ip untrusted", ExtractIPFromRealIPHeader("10.0.0.0/8"), "203.0.113.7", "", "198.51.100.2", "203.0.113.7"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := New()
+			r.IPExtractor = tc.extractor
+			r.GET("/", FromContextHandler(func(c *Context) error {
+				return c.String(fasthttp.StatusOK, c.RealIP())
+			}))
+
+			var req fasthttp.Request
+			req.SetRequestURI("/")
+			if tc.xff != "" {
+				req.Header.Set(HeaderXForwardedFor, tc.xff)
+			}
+			if tc.realIP != "" {
+				req.Header.Set(HeaderXRealIP, tc.realIP)
+			}
+			ctx := new(fasthttp.RequestCtx)
+			ctx.Init(&req, &net.TCPAddr{IP: net.ParseIP(tc.remote), Port: 1234}, discardLogger{})
+			r.Handler(ctx)
+
+			if got := string(ctx.Response.Body()); got != tc.want {
+				t.Errorf("RealIP() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFromContextMWSeesErrors(t *testing.T) {
+	var seen error
+	r := New()
+	r.Use(FromContextMW(func(next ContextHandlerFunc) ContextHandlerFunc {
+		return func(c *Context) error {
+			seen = next(c)
+			return seen
+		}
+	}))
+	r.GET("/", FromContextHandler(func(c *Context) error {
+		return ErrForbidden
+	}))
+
+	ctx := serve(r, "GET", "/")
+	if seen != ErrForbidden || ctx.Response.StatusCode() != fasthttp.StatusForbidden {
+		t.Errorf("seen %v, status %d", seen, ctx.Response.StatusCode())
+	}
+}
@@ -0,0 +1,51 @@
+package routerwithmw
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HTTPErrorHandler is a centralized HTTP error handler.
+type HTTPErrorHandler func(*fasthttp.RequestCtx, error)
+
+// routerKey is the RequestCtx user value key holding the *RouterWithMW
+// serving the request.
+const routerKey = "routerwithmw.router"
+
+// HandleError hands err to the HTTPErrorHandler of the router serving ctx.
+// Outside of a router, DefaultHTTPErrorHandler is used.
+func HandleError(ctx *fasthttp.RequestCtx, err error) {
+	if r := routerOf(ctx); r != nil && r.HTTPErrorHandler != nil {
+		r.HTTPErrorHandler(ctx, err)
+		return
+	}
+	DefaultHTTPErrorHandler(ctx, err)
+}
+
+// httpErrorOf returns the `*HTTPError` in err's chain, or "500 - Internal
+// Server Error" with err as its internal cause. Internal causes are logged
+// to the RequestCtx logger.
+func httpErrorOf(ctx *fasthttp.RequestCtx, err error) *HTTPError {
+	var he *HTTPError
+	if !errors.As(err, &he) {
+		he = ErrInternalServerError.WithInternal(err)
+	}
+	if he.Inner != nil || he != err {
+		ctx.Logger().Printf("%v", err)
+	}
+	return he
+}
+
+// DefaultHTTPErrorHandler is the default HTTP error handler. It sends the
+// status code and public message of an `*HTTPError` as plain text, and "500
+// - Internal Server Error" for any other error. Internal causes are logged,
+// never sent. Unlike ctx.Error it keeps the response headers already set by
+// middleware.
+func DefaultHTTPErrorHandler(ctx *fasthttp.RequestCtx, err error) {
+	he := httpErrorOf(ctx, err)
+	ctx.SetStatusCode(he.Code)
+	ctx.SetContentType(MIMETextPlainCharsetUTF8)
+	ctx.SetBodyString(fmt.Sprintf("%v", he.Message))
+}
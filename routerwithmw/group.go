@@ -0,0 +1,81 @@
+package routerwithmw
+
+import (
+	"github.com/valyala/fasthttp"
+)
+
+// Group is a set of routes sharing a common path prefix and middleware stack.
+// Group middleware wraps only the group's handlers and runs after the
+// router-wide middleware.
+type Group struct {
+	prefix     string
+	middleware []MW
+	router     *RouterWithMW
+}
+
+// Group creates a new route group with the given prefix and optional
+// group-level middleware.
+func (r *RouterWithMW) Group(prefix string, mws ...MW) *Group {
+	g := &Group{prefix: prefix, router: r}
+	g.Use(mws...)
+	return g
+}
+
+// Use appends middleware to the group's stack. It only affects routes
+// registered on the group afterwards.
+func (g *Group) Use(mws ...MW) {
+	g.middleware = append(g.middleware, mws...)
+}
+
+// Group creates a nested group. The nested group's prefix is appended to the
+// parent's and its middleware runs after the parent's.
+func (g *Group) Group(prefix string, mws ...MW) *Group {
+	m := make([]MW, 0, len(g.middleware)+len(mws))
+	m = append(m, g.middleware...)
+	m = append(m, mws...)
+	return g.router.Group(g.prefix+prefix, m...)
+}
+
+// GET is a shortcut for group.Handle("GET", path, handle)
+func (g *Group) GET(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return g.Handle("GET", path, handle, mws...)
+}
+
+// HEAD is a shortcut for group.Handle("HEAD", path, handle)
+func (g *Group) HEAD(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return g.Handle("HEAD", path, handle, mws...)
+}
+
+// OPTIONS is a shortcut for group.Handle("OPTIONS", path, handle)
+func (g *Group) OPTIONS(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return g.Handle("OPTIONS", path, handle, mws...)
+}
+
+// POST is a shortcut for group.Handle("POST", path, handle)
+func (g *Group) POST(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return g.Handle("POST", path, handle, mws...)
+}
+
+// PUT is a shortcut for group.Handle("PUT", path, handle)
+func (g *Group) PUT(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return g.Handle("PUT", path, handle, mws...)
+}
+
+// PATCH is a shortcut for group.Handle("PATCH", path, handle)
+func (g *Group) PATCH(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return g.Handle("PATCH", path, handle, mws...)
+}
+
+// DELETE is a shortcut for group.Handle("DELETE", path, handle)
+func (g *Group) DELETE(path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	return g.Handle("DELETE", path, handle, mws...)
+}
+
+// Handle registers a new request handle under the group's prefix, wrapped
+// with the group's middleware followed by the route-level middleware.
+func (g *Group) Handle(method, path string, handle fasthttp.RequestHandler, mws ...MW) *Route {
+	m := make([]MW, 0, len(g.middleware)+len(mws))
+	m = append(m, g.middleware...)
+	m = append(m, mws...)
+	return g.router.Handle(method, g.prefix+path, handle, m...)
+}
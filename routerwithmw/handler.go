@@ -0,0 +1,81 @@
+package routerwithmw
+
+import (
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// HandlerFunc is a request handler that reports failures by returning an
+	// error instead of writing the error response itself.
+	HandlerFunc func(*fasthttp.RequestCtx) error
+
+	// MWE is the error-aware counterpart of MW. Once adapted with FromMWE,
+	// next returns the errors of the HandlerFuncs below it, so that the
+	// middleware may log, map or swallow them. Errors that plain MW
+	// middleware report with HandleError are rendered right away and are not
+	// seen.
+	MWE = (func(HandlerFunc) HandlerFunc)
+
+	// pendingError holds the error returned below a FromMWE adapter, until
+	// the adapter hands it to its MWE.
+	pendingError struct {
+		err error
+	}
+)
+
+// errorKey is the RequestCtx user value key holding the *pendingError of the
+// innermost FromMWE adapter.
+const errorKey = "routerwithmw.error"
+
+// FromHandlerFunc adapts h to a fasthttp.RequestHandler. A returned error is
+// passed up to the enclosing FromMWE middleware if there is one, or else
+// handed to the centralized HTTPErrorHandler, see HandleError.
+func FromHandlerFunc(h HandlerFunc) fasthttp.RequestHandler {
+	return func(c *fasthttp.RequestCtx) {
+		if err := h(c); err != nil {
+			if p, ok := c.UserValue(errorKey).(*pendingError); ok {
+				p.err = err
+				return
+			}
+			HandleError(c, err)
+		}
+	}
+}
+
+// ToHandlerFunc adapts h to a HandlerFunc that never returns an error.
+func ToHandlerFunc(h fasthttp.RequestHandler) HandlerFunc {
+	return func(c *fasthttp.RequestCtx) error {
+		h(c)
+		return nil
+	}
+}
+
+// FromMWE adapts an error-aware middleware to a MW. The errors returned by
+// the HandlerFuncs below m are returned to m by next; an error returned by m
+// itself goes the same way, up to the next FromMWE middleware or to the
+// centralized HTTPErrorHandler.
+func FromMWE(m MWE) MW {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return FromHandlerFunc(m(func(c *fasthttp.RequestCtx) error {
+			outer := c.UserValue(errorKey)
+			p := &pendingError{}
+			c.SetUserValue(errorKey, p)
+			defer c.SetUserValue(errorKey, outer)
+			next(c)
+			return p.err
+		}))
+	}
+}
+
+// ToMWE adapts m to an error-aware middleware. The error returned by next
+// is passed back through m to the caller.
+func ToMWE(m MW) MWE {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *fasthttp.RequestCtx) (err error) {
+			m(func(c *fasthttp.RequestCtx) {
+				err = next(c)
+			})(c)
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package routerwithmw
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestFromHandlerFunc(t *testing.T) {
+	r := New()
+	r.GET("/ok", FromHandlerFunc(func(c *fasthttp.RequestCtx) error {
+		c.SetBodyString("ok")
+		return nil
+	}))
+	r.GET("/fail", FromHandlerFunc(func(c *fasthttp.RequestCtx) error {
+		return ErrForbidden
+	}))
+
+	if ctx := serve(r, "GET", "/ok"); string(ctx.Response.Body()) != "ok" {
+		t.Errorf("/ok: body = %q", ctx.Response.Body())
+	}
+	if ctx := serve(r, "GET", "/fail"); ctx.Response.StatusCode() != fasthttp.StatusForbidden {
+		t.Errorf("/fail: status = %d, want 403", ctx.Response.StatusCode())
+	}
+}
+
+func TestFromMWESeesDownstreamErrors(t *testing.T) {
+	errBoom := errors.New("boom")
+	var seen []error
+
+	logErrors := FromMWE(func(next HandlerFunc) HandlerFunc {
+		return func(c *fasthttp.RequestCtx) error {
+			err := next(c)
+			seen = append(seen, err)
+			return err
+		}
+	})
+	mapErrors := FromMWE(func(next HandlerFunc) HandlerFunc {
+		return func(c *fasthttp.RequestCtx) error {
+			err := next(c)
+			if errors.Is(err, errBoom) {
+				return ErrBadRequest
+			}
+			return err
+		}
+	})
+
+	r := New()
+	r.Use(logErrors)
+	r.Use(mapErrors)
+	r.GET("/boom", FromHandlerFunc(func(c *fasthttp.RequestCtx) error {
+		return errBoom
+	}))
+	r.GET("/plain", func(c *fasthttp.RequestCtx) {
+		c.SetBodyString("plain")
+	})
+
+	ctx := serve(r, "GET", "/boom")
+	if ctx.Response.StatusCode() != fasthttp.StatusBadRequest {
+		t.Errorf("/boom: status = %d, want 400", ctx.Response.StatusCode())
+	}
+	ctx = serve(r, "GET", "/plain")
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || string(ctx.Response.Body()) != "plain" {
+		t.Errorf("/plain: response = %d %q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if want := []error{ErrBadRequest, nil}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("errors seen by the outer MWE = %v, want %v", seen, want)
+	}
+}
+
+func TestToMWE(t *testing.T) {
+	var log []string
+	m := ToMWE(func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(c *fasthttp.RequestCtx) {
+			log = append(log, "mw")
+			next(c)
+		}
+	})
+	h := m(func(c *fasthttp.RequestCtx) error {
+		log = append(log, "handler")
+		return ErrNotFound
+	})
+
+	if err := h(new(fasthttp.RequestCtx)); err != ErrNotFound {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+	if want := []string{"mw", "handler"}; !reflect.DeepEqual(log, want) {
+		t.Errorf("calls = %q, want %q", log, want)
+	}
+}
+
+func TestToHandlerFunc(t *testing.T) {
+	called := false
+	h := ToHandlerFunc(func(c *fasthttp.RequestCtx) { called = true })
+	if err := h(new(fasthttp.RequestCtx)); err != nil || !called {
+		t.Errorf("err = %v, called = %v", err, called)
+	}
+}
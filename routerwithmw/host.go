@@ -0,0 +1,108 @@
+package routerwithmw
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// HostSwitch dispatches requests to a RouterWithMW by Host header, so each
+// virtual host keeps its own routes and middleware stacks.
+//
+// A host is first matched exactly, then against wildcard-subdomain patterns
+// such as "*.tenant.example.com", the longest pattern winning. A wildcard
+// matches subdomains at any depth but not the bare domain. Ports are ignored
+// and matching is case-insensitive. Requests for other hosts are served by
+// Default, or answered with "404 - Not Found" when it is nil.
+type HostSwitch struct {
+	// Default serves requests for hosts without a router.
+	// Optional. Default value nil.
+	Default *RouterWithMW
+
+	hosts hostMatcher[*RouterWithMW]
+}
+
+// hostMatcher maps host patterns, exact host names or wildcard-subdomain
+// patterns such as "*.tenant.example.com", to values. Matching a host does
+// not allocate unless it contains upper-case letters.
+type hostMatcher[V any] struct {
+	exact     map[string]V
+	wildcards []wildcardHost[V]
+}
+
+type wildcardHost[V any] struct {
+	suffix []byte // e.g. ".tenant.example.com"
+	value  V
+}
+
+// NewHostSwitch returns an empty HostSwitch.
+func NewHostSwitch() *HostSwitch {
+	return &HostSwitch{}
+}
+
+// Host registers r for the host pattern, either an exact host name or a
+// wildcard-subdomain pattern starting with "*.".
+func (hs *HostSwitch) Host(pattern string, r *RouterWithMW) {
+	hs.hosts.add(pattern, r)
+}
+
+// Handler makes the HostSwitch implement the fasthttp.ListenAndServe
+// interface.
+func (hs *HostSwitch) Handler(ctx *fasthttp.RequestCtx) {
+	if r := hs.lookup(ctx.Host()); r != nil {
+		r.Handler(ctx)
+		return
+	}
+	HandleError(ctx, ErrNotFound)
+}
+
+func (hs *HostSwitch) lookup(host []byte) *RouterWithMW {
+	if r, ok := hs.hosts.match(host); ok {
+		return r
+	}
+	return hs.Default
+}
+
+func (m *hostMatcher[V]) add(pattern string, v V) {
+	pattern = strings.ToLower(pattern)
+	if !strings.HasPrefix(pattern, "*.") {
+		if m.exact == nil {
+			m.exact = map[string]V{}
+		}
+		m.exact[pattern] = v
+		return
+	}
+	m.wildcards = append(m.wildcards, wildcardHost[V]{suffix: []byte(pattern[1:]), value: v})
+	sort.SliceStable(m.wildcards, func(i, j int) bool {
+		return len(m.wildcards[i].suffix) > len(m.wildcards[j].suffix)
+	})
+}
+
+// match returns the value of the exact pattern for host, else of the longest
+// matching wildcard pattern. The port of host is ignored.
+func (m *hostMatcher[V]) match(host []byte) (V, bool) {
+	// Strip the port, minding IPv6 literals such as "[::1]:8080".
+	if i := bytes.LastIndexByte(host, ':'); i > bytes.LastIndexByte(host, ']') {
+		host = host[:i]
+	}
+	if bytes.IndexFunc(host, isUpper) >= 0 {
+		host = bytes.ToLower(host)
+	}
+
+	if v, ok := m.exact[string(host)]; ok {
+		return v, true
+	}
+	for _, w := range m.wildcards {
+		if len(host) > len(w.suffix) && bytes.HasSuffix(host, w.suffix) {
+			return w.value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+func isUpper(r rune) bool {
+	return 'A' <= r && r <= 'Z'
+}
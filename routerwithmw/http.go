@@ -0,0 +1,215 @@
+package routerwithmw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"runtime/debug"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+const (
+	// httpCallKey is the RequestCtx user value key holding the *httpCall of
+	// the innermost WrapHTTPMiddleware being served.
+	httpCallKey = "routerwithmw.httpcall"
+
+	// httpContextKey is the RequestCtx user value key holding the request
+	// context seen by the innermost WrapHTTPMiddleware, see RequestContext.
+	httpContextKey = "routerwithmw.httpcontext"
+)
+
+type (
+	// PanicError is the value a panic raised below a WrapHTTPMiddleware is
+	// re-raised with, once it has crossed the goroutine of the net/http
+	// adaptor. Stack is the stack of the goroutine that panicked.
+	PanicError struct {
+		Value interface{}
+		Stack []byte
+	}
+
+	// httpCall is the state of a request going through a WrapHTTPMiddleware.
+	httpCall struct {
+		c *fasthttp.RequestCtx
+
+		// body is the request body the middleware is called with.
+		body io.ReadCloser
+
+		// panic is set when the chain below the middleware panicked.
+		panic *PanicError
+	}
+)
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%v", e.Value)
+}
+
+// Unwrap returns Value if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// RequestContext returns the context of the net/http request seen by the
+// innermost WrapHTTPMiddleware around c, with the values its middleware
+// added with `r.WithContext()`. Outside of a WrapHTTPMiddleware it returns c,
+// whose values are its user values.
+func RequestContext(c *fasthttp.RequestCtx) context.Context {
+	if ctx, ok := c.UserValue(httpContextKey).(context.Context); ok {
+		return ctx
+	}
+	return c
+}
+
+// WrapHTTPHandler adapts a net/http handler so it can be registered as a
+// route. Route params and other RequestCtx user values are available through
+// the request context, e.g. `r.Context().Value("id")`, along with the values
+// added by enclosing WrapHTTPMiddleware, see RequestContext.
+func WrapHTTPHandler(h http.Handler) fasthttp.RequestHandler {
+	return fasthttpadaptor.NewFastHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().(*fasthttp.RequestCtx)
+		h.ServeHTTP(w, r.WithContext(RequestContext(c)))
+	}))
+}
+
+// WrapHTTPMiddleware adapts a net/http middleware to a MW.
+//
+// The http.Handler passed to m runs the rest of the chain on the RequestCtx.
+// The changes m made to the request are copied to the RequestCtx first:
+// method, URI, headers set or deleted and a replaced body, so that e.g.
+// `http.StripPrefix` and `http.MaxBytesReader` apply; the values m added to
+// the request context are available through RequestContext. The response of
+// the chain, status code included, is then written to m's ResponseWriter, so
+// m can observe or wrap it as with net/http. A response written by m without
+// calling the handler is sent as is.
+//
+// The net/http adaptor calls m on a goroutine of its own, and so the rest of
+// the chain, while the calling goroutine waits. A panic below m goes through
+// m, and is then re-raised on the calling goroutine as a *PanicError holding
+// the original value and stack. m must not flush or hijack the response.
+func WrapHTTPMiddleware(m func(http.Handler) http.Handler) MW {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			call := r.Context().Value(httpCallKey).(*httpCall)
+			c := call.c
+			if err := syncRequest(c, r, call.body); err != nil {
+				HandleError(c, err)
+			} else {
+				call.serve(next, r.Context())
+			}
+
+			// The adaptor copies w back to the RequestCtx once m returns.
+			c.Response.Header.VisitAll(func(k, v []byte) {
+				if string(k) != HeaderContentLength {
+					w.Header().Add(string(k), string(v))
+				}
+			})
+			w.WriteHeader(c.Response.StatusCode())
+			w.Write(c.Response.Body())
+			c.Response.Reset()
+		})
+		mh := m(inner)
+		h := fasthttpadaptor.NewFastHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := r.Context().(*fasthttp.RequestCtx)
+			c.UserValue(httpCallKey).(*httpCall).body = r.Body
+			mh.ServeHTTP(w, r.WithContext(RequestContext(c)))
+		}))
+
+		return func(c *fasthttp.RequestCtx) {
+			call := &httpCall{c: c}
+			outer := c.UserValue(httpCallKey)
+			c.SetUserValue(httpCallKey, call)
+			defer func() {
+				c.SetUserValue(httpCallKey, outer)
+				if call.panic != nil {
+					if recover() != nil {
+						panic(call.panic)
+					}
+				}
+			}()
+			h(c)
+		}
+	}
+}
+
+// serve runs next with ctx as the request context, recording a panic before
+// letting it go up through the net/http middleware.
+func (call *httpCall) serve(next fasthttp.RequestHandler, ctx context.Context) {
+	c := call.c
+	outer := c.UserValue(httpContextKey)
+	c.SetUserValue(httpContextKey, ctx)
+	defer func() {
+		c.SetUserValue(httpContextKey, outer)
+		if v := recover(); v != nil {
+			if pe, ok := v.(*PanicError); ok {
+				call.panic = pe
+			} else {
+				call.panic = &PanicError{Value: v, Stack: debug.Stack()}
+			}
+			panic(v)
+		}
+	}()
+	next(c)
+}
+
+// syncRequest copies the changes a net/http middleware made to r to the
+// request of c. body is the body r had before the middleware.
+func syncRequest(c *fasthttp.RequestCtx, r *http.Request, body io.ReadCloser) error {
+	req := &c.Request
+	if r.Method != string(req.Header.Method()) {
+		req.Header.SetMethod(r.Method)
+	}
+	if uri := r.URL.RequestURI(); uri != string(req.RequestURI()) {
+		req.SetRequestURI(uri)
+	}
+	if r.Host != string(req.Host()) {
+		req.SetHost(r.Host)
+	}
+
+	// The values of r.Header may share memory with req.Header, so they are
+	// copied before req.Header is changed.
+	header := make(map[string][]string, len(r.Header))
+	for k, vv := range r.Header {
+		for _, v := range vv {
+			header[k] = append(header[k], strings.Clone(v))
+		}
+	}
+	var deleted []string
+	req.Header.VisitAll(func(k, v []byte) {
+		key := textproto.CanonicalMIMEHeaderKey(string(k))
+		switch key {
+		case fasthttp.HeaderHost, HeaderContentLength, fasthttp.HeaderTransferEncoding:
+			return
+		}
+		if _, ok := header[key]; !ok {
+			deleted = append(deleted, key)
+		}
+	})
+	for _, k := range deleted {
+		req.Header.Del(k)
+	}
+	for k, vv := range header {
+		req.Header.Del(k)
+		for _, v := range vv {
+			req.Header.Add(k, v)
+		}
+	}
+
+	if r.Body != body {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			var mbe *http.MaxBytesError
+			if errors.As(err, &mbe) {
+				return ErrStatusRequestEntityTooLarge.WithInternal(err)
+			}
+			return ErrBadRequest.WithInternal(err)
+		}
+		req.SetBody(b)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package routerwithmw
 
+import "errors"
 import "fmt"
+import "reflect"
 import http "github.com/valyala/fasthttp"
 
 // Auxillary consts
@@ -23,6 +25,8 @@ const (
 	MIMETextPlainCharsetUTF8             = MIMETextPlain + "; " + charsetUTF8
 	MIMEMultipartForm                    = "multipart/form-data"
 	MIMEOctetStream                      = "application/octet-stream"
+	MIMEApplicationProblemJSON           = "application/problem+json"
+	MIMEApplicationProblemXML            = "application/problem+xml"
 )
 
 const (
@@ -59,14 +63,16 @@ const (
 	HeaderOrigin              = "Origin"
 
 	// Access control
-	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
-	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
-	HeaderAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
-	HeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
-	HeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
-	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
-	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
-	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+	HeaderAccessControlRequestMethod         = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders        = "Access-Control-Request-Headers"
+	HeaderAccessControlRequestPrivateNetwork = "Access-Control-Request-Private-Network"
+	HeaderAccessControlAllowOrigin           = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowMethods          = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders          = "Access-Control-Allow-Headers"
+	HeaderAccessControlAllowCredentials      = "Access-Control-Allow-Credentials"
+	HeaderAccessControlAllowPrivateNetwork   = "Access-Control-Allow-Private-Network"
+	HeaderAccessControlExposeHeaders         = "Access-Control-Expose-Headers"
+	HeaderAccessControlMaxAge                = "Access-Control-Max-Age"
 
 	// Security
 	HeaderStrictTransportSecurity = "Strict-Transport-Security"
@@ -77,12 +83,35 @@ const (
 	HeaderXCSRFToken              = "X-CSRF-Token"
 )
 
+// Errors
+var (
+	ErrBadRequest                  = NewHTTPError(http.StatusBadRequest)
+	ErrUnauthorized                = NewHTTPError(http.StatusUnauthorized)
+	ErrForbidden                   = NewHTTPError(http.StatusForbidden)
+	ErrNotFound                    = NewHTTPError(http.StatusNotFound)
+	ErrMethodNotAllowed            = NewHTTPError(http.StatusMethodNotAllowed)
+	ErrNotAcceptable               = NewHTTPError(http.StatusNotAcceptable)
+	ErrStatusRequestEntityTooLarge = NewHTTPError(http.StatusRequestEntityTooLarge)
+	ErrUnsupportedMediaType        = NewHTTPError(http.StatusUnsupportedMediaType)
+	ErrInternalServerError         = NewHTTPError(http.StatusInternalServerError)
+	ErrInvalidRedirectCode         = errors.New("invalid redirect status code")
+)
+
 //HTTPError struct
 
 type HTTPError struct {
 	Code    int
-	Message interface{}
-	Inner   error // Stores the error returned by an external dependency
+	Message interface{} // Public message, sent to clients
+	Inner   error       // Internal cause, only logged. See WithInternal.
+
+	// Optional RFC 7807 problem details members, used by
+	// ProblemHTTPErrorHandler. Title defaults to the status text and Detail
+	// to Message.
+	Type       string
+	Title      string
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
 }
 
 //HTTPError's Error interface implementation
@@ -97,5 +126,30 @@ func NewHTTPError(code int, message ...interface{}) *HTTPError {
 
 // Error makes it compatible with `error` interface.
 func (he *HTTPError) Error() string {
-	return fmt.Sprintf("code=%d, message=%v", he.Code, he.Message)
+	if he.Inner == nil {
+		return fmt.Sprintf("code=%d, message=%v", he.Code, he.Message)
+	}
+	return fmt.Sprintf("code=%d, message=%v, internal=%v", he.Code, he.Message, he.Inner)
+}
+
+// WithInternal returns a copy of he with its internal cause set to err. The
+// internal cause is logged by the error handlers but never sent to clients.
+// Copying keeps shared errors such as ErrUnauthorized untouched.
+func (he *HTTPError) WithInternal(err error) *HTTPError {
+	c := *he
+	c.Inner = err
+	return &c
+}
+
+// Unwrap returns the internal cause, for `errors.Is` and `errors.As`.
+func (he *HTTPError) Unwrap() error {
+	return he.Inner
+}
+
+// Is reports whether target is an `*HTTPError` with the same code and
+// message, so that a copy made by WithInternal still matches the error it
+// was made from.
+func (he *HTTPError) Is(target error) bool {
+	t, ok := target.(*HTTPError)
+	return ok && t.Code == he.Code && reflect.DeepEqual(t.Message, he.Message)
 }
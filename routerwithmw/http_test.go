@@ -0,0 +1,169 @@
+package routerwithmw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type ctxKey string
+
+func TestWrapHTTPMiddlewareRequestChanges(t *testing.T) {
+	r := New()
+	r.Use(WrapHTTPMiddleware(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.Header.Del("X-Remove")
+			req.Header.Set("X-Add", "added")
+			req.Method = "PUT"
+			ctx := context.WithValue(req.Context(), ctxKey("user"), "bob")
+			h.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}))
+	r.Use(WrapHTTPMiddleware(func(h http.Handler) http.Handler {
+		return http.StripPrefix("/api", h)
+	}))
+	r.GET("/api/items", func(c *fasthttp.RequestCtx) {
+		c.Response.Header.Set("X-Path", string(c.Path()))
+		c.Response.Header.Set("X-Method", string(c.Method()))
+		c.Response.Header.Set("X-Remove", string(c.Request.Header.Peek("X-Remove")))
+		c.Response.Header.Set("X-Add", string(c.Request.Header.Peek("X-Add")))
+		c.Response.Header.Set("X-User", RequestContext(c).Value(ctxKey("user")).(string))
+		c.Response.Header.Set("X-Query", string(c.QueryArgs().Peek("q")))
+	})
+
+	var req fasthttp.Request
+	req.SetRequestURI("/api/items?q=1")
+	req.Header.Set("X-Remove", "gone")
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&req, nil, discardLogger{})
+	r.Handler(ctx)
+
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Fatalf("status = %d: %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	for k, want := range map[string]string{
+		"X-Path":   "/items",
+		"X-Method": "PUT",
+		"X-Remove": "",
+		"X-Add":    "added",
+		"X-User":   "bob",
+		"X-Query":  "1",
+	} {
+		if got := string(ctx.Response.Header.Peek(k)); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestWrapHTTPMiddlewareBody(t *testing.T) {
+	r := New()
+	r.Use(WrapHTTPMiddleware(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			req.Body = http.MaxBytesReader(w, req.Body, 4)
+			h.ServeHTTP(w, req)
+		})
+	}))
+	r.POST("/", func(c *fasthttp.RequestCtx) {
+		c.SetBody(c.PostBody())
+	})
+
+	for body, want := range map[string]int{
+		"abc":         fasthttp.StatusOK,
+		"abcdefghijk": fasthttp.StatusRequestEntityTooLarge,
+	} {
+		var req fasthttp.Request
+		req.Header.SetMethod("POST")
+		req.SetRequestURI("/")
+		req.SetBodyString(body)
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Init(&req, nil, discardLogger{})
+		r.Handler(ctx)
+
+		if ctx.Response.StatusCode() != want {
+			t.Errorf("body %q: status = %d, want %d", body, ctx.Response.StatusCode(), want)
+		}
+		if want == fasthttp.StatusOK && string(ctx.Response.Body()) != body {
+			t.Errorf("body %q: echoed %q", body, ctx.Response.Body())
+		}
+	}
+}
+
+func TestWrapHTTPMiddlewareResponse(t *testing.T) {
+	r := New()
+	r.Use(WrapHTTPMiddleware(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Query().Get("deny") != "" {
+				http.Error(w, "denied", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("X-Outer", "1")
+			h.ServeHTTP(w, req)
+		})
+	}))
+	r.GET("/", func(c *fasthttp.RequestCtx) {
+		c.SetStatusCode(fasthttp.StatusCreated)
+		c.SetBodyString("created")
+	})
+
+	ctx := serve(r, "GET", "/")
+	if ctx.Response.StatusCode() != fasthttp.StatusCreated || string(ctx.Response.Body()) != "created" {
+		t.Errorf("response = %d %q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	if got := string(ctx.Response.Header.Peek("X-Outer")); got != "1" {
+		t.Errorf("X-Outer = %q", got)
+	}
+	ctx = serve(r, "GET", "/?deny=1")
+	if ctx.Response.StatusCode() != fasthttp.StatusForbidden || !strings.Contains(string(ctx.Response.Body()), "denied") {
+		t.Errorf("denied response = %d %q", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+}
+
+func TestWrapHTTPMiddlewarePanic(t *testing.T) {
+	errBoom := errors.New("boom")
+	var recovered interface{}
+
+	r := New()
+	r.Use(func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(c *fasthttp.RequestCtx) {
+			defer func() { recovered = recover() }()
+			next(c)
+		}
+	})
+	r.Use(WrapHTTPMiddleware(func(h http.Handler) http.Handler { return h }))
+	r.GET("/", func(c *fasthttp.RequestCtx) {
+		panic(errBoom)
+	})
+
+	serve(r, "GET", "/")
+	pe, ok := recovered.(*PanicError)
+	if !ok {
+		t.Fatalf("recovered %#v, want a *PanicError", recovered)
+	}
+	if !errors.Is(pe, errBoom) {
+		t.Errorf("Value = %v, want %v", pe.Value, errBoom)
+	}
+	if !strings.Contains(string(pe.Stack), "TestWrapHTTPMiddlewarePanic") {
+		t.Errorf("Stack does not show the handler:\n%s", pe.Stack)
+	}
+}
+
+func TestWrapHTTPHandler(t *testing.T) {
+	r := New()
+	r.Use(WrapHTTPMiddleware(func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			h.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), ctxKey("user"), "bob")))
+		})
+	}))
+	r.GET("/users/:id", WrapHTTPHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, req.Context().Value("id").(string)+" "+req.Context().Value(ctxKey("user")).(string))
+	})))
+
+	if got := string(serve(r, "GET", "/users/7").Response.Body()); got != "7 bob" {
+		t.Errorf("body = %q, want %q", got, "7 bob")
+	}
+}
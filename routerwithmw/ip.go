@@ -0,0 +1,107 @@
+package routerwithmw
+
+import (
+	"net"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// IPExtractor returns the IP address of the client that sent the request.
+// See `Context.RealIP()`.
+type IPExtractor func(*fasthttp.RequestCtx) string
+
+// ExtractIPDirect returns an IPExtractor using the address of the peer of the
+// connection. It is the right choice when the server is exposed directly to
+// its clients, and is what RealIP uses when the router has no IPExtractor.
+func ExtractIPDirect() IPExtractor {
+	return func(c *fasthttp.RequestCtx) string {
+		return c.RemoteIP().String()
+	}
+}
+
+// ExtractIPFromXFFHeader returns an IPExtractor reading the
+// `X-Forwarded-For` header set by trusted proxies. trustedProxies are IP
+// addresses or CIDR ranges, e.g. "10.0.0.0/8".
+//
+// The header is only used when the peer is a trusted proxy. Its addresses are
+// then read from right to left, and the first one that is not a trusted proxy
+// is returned, so that addresses prepended by the client are ignored.
+func ExtractIPFromXFFHeader(trustedProxies ...string) IPExtractor {
+	trusted := parseIPRanges(trustedProxies)
+	return func(c *fasthttp.RequestCtx) string {
+		ip := c.RemoteIP()
+		if !trusted.contains(ip) {
+			return ip.String()
+		}
+		xff := string(c.Request.Header.Peek(HeaderXForwardedFor))
+		for xff != "" {
+			var hop string
+			if i := strings.LastIndexByte(xff, ','); i >= 0 {
+				hop, xff = xff[i+1:], xff[:i]
+			} else {
+				hop, xff = xff, ""
+			}
+			hopIP := net.ParseIP(strings.TrimSpace(hop))
+			if hopIP == nil {
+				break
+			}
+			ip = hopIP
+			if !trusted.contains(ip) {
+				break
+			}
+		}
+		return ip.String()
+	}
+}
+
+// ExtractIPFromRealIPHeader returns an IPExtractor reading the `X-Real-IP`
+// header, when the peer is one of trustedProxies. trustedProxies are IP
+// addresses or CIDR ranges, e.g. "10.0.0.0/8".
+func ExtractIPFromRealIPHeader(trustedProxies ...string) IPExtractor {
+	trusted := parseIPRanges(trustedProxies)
+	return func(c *fasthttp.RequestCtx) string {
+		ip := c.RemoteIP()
+		if trusted.contains(ip) {
+			if realIP := net.ParseIP(string(c.Request.Header.Peek(HeaderXRealIP))); realIP != nil {
+				return realIP.String()
+			}
+		}
+		return ip.String()
+	}
+}
+
+type ipRanges []*net.IPNet
+
+func parseIPRanges(ranges []string) ipRanges {
+	nets := make(ipRanges, 0, len(ranges))
+	for _, r := range ranges {
+		if !strings.Contains(r, "/") {
+			ip := net.ParseIP(r)
+			if ip == nil {
+				panic("routerwithmw: invalid trusted proxy '" + r + "'")
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(r)
+		if err != nil {
+			panic("routerwithmw: invalid trusted proxy '" + r + "'")
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+func (nets ipRanges) contains(ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package routerwithmw
+
+import (
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// MountConfig defines the config for MountWithConfig.
+	MountConfig struct {
+		// KeepPrefix passes the full request path to the mounted router
+		// instead of stripping the mount prefix from it.
+		// Optional. Default value false.
+		KeepPrefix bool
+
+		// Middleware wraps the mounted router, after the parent's middleware.
+		// Optional. Default value nil.
+		Middleware []MW
+	}
+
+	// mount is a router mounted under a prefix, shared by the routes
+	// registered for it.
+	mount struct {
+		prefix string
+		sub    *RouterWithMW
+		config MountConfig
+	}
+)
+
+const (
+	// mountParam is the name of the catch-all param of mount routes.
+	mountParam = "routerwithmw.mount"
+
+	// mountPrefixKey is the RequestCtx user value key holding the prefixes
+	// stripped by the mounts a request went through.
+	mountPrefixKey = "routerwithmw.mountprefix"
+)
+
+// mountMethods are the methods routed to a mounted router.
+var mountMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE"}
+
+// Mount attaches sub under prefix, stripping the prefix from the path seen
+// by sub. Requests go through the parent's middleware, then mws, then sub's
+// own `Pre` and `Use` middleware. sub routes the request as if served on its
+// own, without the parent's route; the path and routing state seen by the
+// parent's middleware are restored once sub returns, and the redirects sent
+// by sub's underlying router keep the prefix.
+// See `MountWithConfig()`.
+func (r *RouterWithMW) Mount(prefix string, sub *RouterWithMW, mws ...MW) {
+	r.MountWithConfig(prefix, sub, MountConfig{Middleware: mws})
+}
+
+// MountWithConfig attaches sub under prefix with config. The prefix must not
+// end with a slash and may not overlap with other routes of r.
+func (r *RouterWithMW) MountWithConfig(prefix string, sub *RouterWithMW, config MountConfig) {
+	if prefix == "" || prefix[0] != '/' || strings.HasSuffix(prefix, "/") {
+		panic("routerwithmw: mount prefix must begin and not end with '/' in prefix '" + prefix + "'")
+	}
+
+	m := &mount{prefix: prefix, sub: sub, config: config}
+	handle := func(ctx *fasthttp.RequestCtx) {
+		path := append([]byte(nil), ctx.URI().PathOriginal()...)
+		route := ctx.UserValue(routeKey)
+		rest, _ := ctx.UserValue(mountParam).(string)
+		outerPrefix, _ := ctx.UserValue(mountPrefixKey).(string)
+		defer func() {
+			ctx.URI().SetPathBytes(path)
+			ctx.SetUserValue(routerKey, r)
+			ctx.SetUserValue(routeKey, route)
+			if rest != "" {
+				ctx.SetUserValue(mountParam, rest)
+			}
+			ctx.SetUserValue(mountPrefixKey, outerPrefix)
+		}()
+
+		// sub routes the request afresh: its `Pre` middleware and fallback
+		// must not see the parent's route.
+		ctx.SetUserValue(routeKey, nil)
+		ctx.RemoveUserValue(mountParam)
+
+		if !config.KeepPrefix {
+			if rest == "" {
+				rest = "/"
+			}
+			ctx.URI().SetPath(rest)
+			ctx.SetUserValue(mountPrefixKey, outerPrefix+prefix)
+		}
+		sub.Handler(ctx)
+	}
+	for _, method := range mountMethods {
+		r.Handle(method, prefix, handle, config.Middleware...).mount = m
+		r.Handle(method, prefix+"/*"+mountParam, handle, config.Middleware...).mount = m
+	}
+}
+
+// prefixRedirect adds the prefixes stripped by mounts back to the location
+// of a redirect sent by the underlying router, which only knows the path
+// seen by the mounted router.
+func prefixRedirect(ctx *fasthttp.RequestCtx) {
+	prefix, _ := ctx.UserValue(mountPrefixKey).(string)
+	code := ctx.Response.StatusCode()
+	if prefix == "" || (code != fasthttp.StatusMovedPermanently && code != fasthttp.StatusTemporaryRedirect) {
+		return
+	}
+	location := ctx.Response.Header.Peek(HeaderLocation)
+	if location == nil {
+		return
+	}
+	u := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(u)
+	if err := u.Parse(nil, location); err != nil {
+		return
+	}
+	u.SetPath(prefix + string(u.Path()))
+	ctx.Response.Header.Set(HeaderLocation, u.String())
+}
+
+// routes returns the routes of the mounted router as seen from the parent
+// router r, after the middleware of r listed in mws.
+func (m *mount) routes(mws []string) []RouteInfo {
+	routes := m.sub.Routes()
+	for i := range routes {
+		if !m.config.KeepPrefix {
+			routes[i].Path = m.prefix + routes[i].Path
+		}
+		chain := make([]string, 0, len(mws)+len(routes[i].Middleware))
+		routes[i].Middleware = append(append(chain, mws...), routes[i].Middleware...)
+	}
+	return routes
+}
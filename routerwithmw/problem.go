@@ -0,0 +1,152 @@
+package routerwithmw
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// problemXMLNamespace is the XML namespace of RFC 7807 problem documents.
+const problemXMLNamespace = "urn:ietf:rfc:7807"
+
+// ProblemHTTPErrorHandler is an HTTP error handler rendering errors as RFC 7807
+// problem details. It sends `application/problem+xml` when the Accept header
+// prefers XML, and `application/problem+json` otherwise. Errors other than
+// `*HTTPError` are rendered as "500 - Internal Server Error". Internal causes
+// are logged, never sent.
+//
+// Enable it with `router.HTTPErrorHandler = routerwithmw.ProblemHTTPErrorHandler`.
+// See: https://tools.ietf.org/html/rfc7807
+func ProblemHTTPErrorHandler(ctx *fasthttp.RequestCtx, err error) {
+	he := httpErrorOf(ctx, err)
+	members := problemMembers(he)
+
+	var (
+		body []byte
+		mime string
+	)
+	if strings.HasSuffix(Negotiate(ctx, problemOffers...), "xml") {
+		body, err = marshalProblemXML(members)
+		mime = MIMEApplicationProblemXML
+	} else {
+		body, err = json.Marshal(members)
+		mime = MIMEApplicationProblemJSON
+	}
+	if err != nil {
+		ctx.Logger().Printf("%v", err)
+		DefaultHTTPErrorHandler(ctx, he)
+		return
+	}
+	ctx.SetStatusCode(he.Code)
+	ctx.SetContentType(mime)
+	ctx.SetBody(body)
+}
+
+// problemMembers returns the members of the problem document for he. The
+// standard members take precedence over extension members of the same name,
+// even when they are left out of the document.
+func problemMembers(he *HTTPError) map[string]interface{} {
+	m := make(map[string]interface{}, len(he.Extensions)+5)
+	for k, v := range he.Extensions {
+		if !problemStandardMembers[k] {
+			m[k] = v
+		}
+	}
+	if he.Type != "" {
+		m["type"] = he.Type
+	}
+	m["title"] = he.Title
+	if he.Title == "" {
+		m["title"] = fasthttp.StatusMessage(he.Code)
+	}
+	m["status"] = he.Code
+	if he.Detail != "" {
+		m["detail"] = he.Detail
+	} else if msg := fmt.Sprintf("%v", he.Message); msg != fasthttp.StatusMessage(he.Code) {
+		m["detail"] = msg
+	}
+	if he.Instance != "" {
+		m["instance"] = he.Instance
+	}
+	return m
+}
+
+// problemStandardMembers are the members defined by RFC 7807.
+var problemStandardMembers = map[string]bool{
+	"type":     true,
+	"title":    true,
+	"status":   true,
+	"detail":   true,
+	"instance": true,
+}
+
+// problemOffers are the media types accepted for problem documents; the XML
+// ones select `application/problem+xml`.
+var problemOffers = []string{
+	MIMEApplicationProblemJSON,
+	MIMEApplicationProblemXML,
+	MIMEApplicationJSON,
+	MIMEApplicationXML,
+	MIMETextXML,
+}
+
+// marshalProblemXML encodes members as described in RFC 7807 appendix A:
+// members become child elements of <problem>, and array items are wrapped in
+// <i> elements.
+func marshalProblemXML(members map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	e := xml.NewEncoder(&buf)
+	if err := encodeXMLMap(e, xml.StartElement{
+		Name: xml.Name{Space: problemXMLNamespace, Local: "problem"},
+	}, members); err != nil {
+		return nil, err
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXMLMap(e *xml.Encoder, start xml.StartElement, m map[string]interface{}) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := encodeXMLValue(e, xml.StartElement{Name: xml.Name{Local: k}}, m[k]); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+func encodeXMLValue(e *xml.Encoder, start xml.StartElement, v interface{}) error {
+	if m, ok := v.(map[string]interface{}); ok {
+		return encodeXMLMap(e, start, m)
+	}
+	rv := reflect.ValueOf(v)
+	if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeXMLValue(e, xml.StartElement{Name: xml.Name{Local: "i"}}, rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	}
+	return e.EncodeElement(v, start)
+}
@@ -0,0 +1,142 @@
+package routerwithmw
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func serveProblem(t *testing.T, err error, accept string) *fasthttp.RequestCtx {
+	t.Helper()
+	r := New()
+	r.HTTPErrorHandler = ProblemHTTPErrorHandler
+	r.GET("/", func(ctx *fasthttp.RequestCtx) {
+		HandleError(ctx, err)
+	})
+
+	var req fasthttp.Request
+	req.SetRequestURI("/")
+	if accept != "" {
+		req.Header.Set(HeaderAccept, accept)
+	}
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&req, nil, discardLogger{})
+	r.Handler(ctx)
+	return ctx
+}
+
+func TestProblemContentType(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                                 MIMEApplicationProblemJSON,
+		"*/*":                              MIMEApplicationProblemJSON,
+		"application/json":                 MIMEApplicationProblemJSON,
+		"application/xml":                  MIMEApplicationProblemXML,
+		"text/xml":                         MIMEApplicationProblemXML,
+		"application/problem+xml":          MIMEApplicationProblemXML,
+		"application/json;q=0.5, text/xml": MIMEApplicationProblemXML,
+		"text/html":                        MIMEApplicationProblemJSON,
+	} {
+		ctx := serveProblem(t, ErrNotFound, accept)
+		if got := string(ctx.Response.Header.ContentType()); got != want {
+			t.Errorf("Accept %q: Content-Type = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestProblemJSONMembers(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		want map[string]interface{}
+	}{
+		{"defaults", ErrNotFound, map[string]interface{}{
+			"title": "Not Found", "status": 404.0,
+		}},
+		{"message as detail", NewHTTPError(fasthttp.StatusBadRequest, "missing name"), map[string]interface{}{
+			"title": "Bad Request", "status": 400.0, "detail": "missing name",
+		}},
+		{"explicit members", &HTTPError{
+			Code:     fasthttp.StatusForbidden,
+			Message:  "ignored",
+			Type:     "https://example.com/probs/out-of-credit",
+			Title:    "You do not have enough credit.",
+			Detail:   "Your current balance is 30, but that costs 50.",
+			Instance: "/account/12345/msgs/abc",
+		}, map[string]interface{}{
+			"type":     "https://example.com/probs/out-of-credit",
+			"title":    "You do not have enough credit.",
+			"status":   403.0,
+			"detail":   "Your current balance is 30, but that costs 50.",
+			"instance": "/account/12345/msgs/abc",
+		}},
+		{"extensions", &HTTPError{
+			Code:    fasthttp.StatusConflict,
+			Message: fasthttp.StatusMessage(fasthttp.StatusConflict),
+			Extensions: map[string]interface{}{
+				"balance": 30,
+				"status":  200,
+				"title":   "overridden",
+				"type":    "overridden",
+			},
+		}, map[string]interface{}{
+			"title": "Conflict", "status": 409.0, "balance": 30.0,
+		}},
+		{"internal error", errTest, map[string]interface{}{
+			"title": "Internal Server Error", "status": 500.0,
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := serveProblem(t, tc.err, "")
+			var got map[string]interface{}
+			if err := json.Unmarshal(ctx.Response.Body(), &got); err != nil {
+				t.Fatalf("%v: %s", err, ctx.Response.Body())
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("members = %v, want %v", got, tc.want)
+			}
+			if code := ctx.Response.StatusCode(); float64(code) != tc.want["status"] {
+				t.Errorf("status = %d, want %v", code, tc.want["status"])
+			}
+		})
+	}
+}
+
+func TestProblemXML(t *testing.T) {
+	err := &HTTPError{
+		Code:    fasthttp.StatusUnprocessableEntity,
+		Message: "invalid request",
+		Extensions: map[string]interface{}{
+			"errors": []string{"name", "age"},
+			"limits": map[string]interface{}{"max": 3},
+		},
+	}
+	ctx := serveProblem(t, err, "application/xml")
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<problem xmlns="urn:ietf:rfc:7807">` +
+		`<detail>invalid request</detail>` +
+		`<errors><i>name</i><i>age</i></errors>` +
+		`<limits><max>3</max></limits>` +
+		`<status>422</status>` +
+		`<title>Unprocessable Entity</title>` +
+		`</problem>`
+	if got := string(ctx.Response.Body()); got != want {
+		t.Errorf("body:\n got %s\nwant %s", got, want)
+	}
+}
+
+var errTest = &testError{"database is down"}
+
+type testError struct{ msg string }
+
+func (e *testError) Error() string { return e.msg }
+
+func TestProblemHidesInternalCause(t *testing.T) {
+	ctx := serveProblem(t, ErrForbidden.WithInternal(errTest), "")
+	if body := string(ctx.Response.Body()); strings.Contains(body, errTest.msg) {
+		t.Errorf("internal cause sent to the client: %s", body)
+	}
+}
@@ -0,0 +1,188 @@
+package routerwithmw
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/valyala/fasthttp"
+	"github.com/vmihailenco/msgpack"
+)
+
+// HTMLMarshaler is implemented by values that can render themselves as HTML,
+// making `text/html` available to Render.
+type HTMLMarshaler interface {
+	MarshalHTML() ([]byte, error)
+}
+
+// Negotiate returns the offered media type the Accept header of c prefers,
+// honouring quality values and the specificity of media ranges. Ties go to
+// the earliest offer. Without an Accept header the first offer is returned,
+// and "" is returned when no offer is acceptable.
+func Negotiate(c *fasthttp.RequestCtx, offers ...string) string {
+	return negotiate(string(c.Request.Header.Peek(HeaderAccept)), offers)
+}
+
+// Render sends i with status code, encoded in the format preferred by the
+// Accept header: JSON, XML if i is a struct, a pointer to a struct or an
+// xml.Marshaler, msgpack, protobuf if i implements proto.Message,
+// HTML if i is a template.HTML or an HTMLMarshaler, or plain text if i is a
+// string, an error or a fmt.Stringer. HTML is offered first, then text, then
+// JSON. It returns "406 - Not Acceptable" when the client accepts none of
+// them.
+func Render(c *fasthttp.RequestCtx, code int, i interface{}) error {
+	c.Response.Header.Add(HeaderVary, HeaderAccept)
+
+	var (
+		b   []byte
+		err error
+	)
+	mime := Negotiate(c, renderOffers(i)...)
+	switch mime {
+	case MIMEApplicationJSON:
+		b, err = json.Marshal(i)
+		mime = MIMEApplicationJSONCharsetUTF8
+	case MIMEApplicationXML, MIMETextXML:
+		if b, err = xml.Marshal(i); err == nil {
+			b = append([]byte(xml.Header), b...)
+		}
+		mime += "; " + charsetUTF8
+	case MIMEApplicationMsgpack:
+		b, err = msgpack.Marshal(i)
+	case MIMEApplicationProtobuf:
+		b, err = proto.Marshal(i.(proto.Message))
+	case MIMETextHTML:
+		if h, ok := i.(template.HTML); ok {
+			b = []byte(h)
+		} else {
+			b, err = i.(HTMLMarshaler).MarshalHTML()
+		}
+		mime = MIMETextHTMLCharsetUTF8
+	case MIMETextPlain:
+		b = []byte(fmt.Sprint(i))
+		mime = MIMETextPlainCharsetUTF8
+	default:
+		return ErrNotAcceptable
+	}
+	if err != nil {
+		return err
+	}
+
+	c.SetStatusCode(code)
+	c.SetContentType(mime)
+	c.SetBody(b)
+	return nil
+}
+
+// Render sends i with status code in the format preferred by the client, see
+// `Render()`.
+func (c *Context) Render(code int, i interface{}) error {
+	return Render(c.RequestCtx, code, i)
+}
+
+// renderOffers returns the media types Render can encode i to, in order of
+// preference.
+func renderOffers(i interface{}) []string {
+	data := []string{MIMEApplicationJSON}
+	if xmlDocument(i) {
+		data = append(data, MIMEApplicationXML, MIMETextXML)
+	}
+	data = append(data, MIMEApplicationMsgpack)
+	if _, ok := i.(proto.Message); ok {
+		data = append(data, MIMEApplicationProtobuf)
+	}
+
+	// Other values would be sent as Go syntax.
+	var text []string
+	switch i.(type) {
+	case string, template.HTML, error, fmt.Stringer:
+		text = []string{MIMETextPlain}
+	}
+
+	switch i.(type) {
+	case template.HTML, HTMLMarshaler:
+		return append(append([]string{MIMETextHTML}, text...), data...)
+	}
+	return append(text, data...)
+}
+
+// xmlDocument reports whether i encodes to a single XML document. Maps
+// cannot be encoded and slices encode to several root elements.
+func xmlDocument(i interface{}) bool {
+	if _, ok := i.(xml.Marshaler); ok {
+		return true
+	}
+	t := reflect.TypeOf(i)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t != nil && t.Kind() == reflect.Struct
+}
+
+// negotiate implements Negotiate for the Accept header value accept.
+func negotiate(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		typ, subtype string
+		q            float64
+	}
+	ranges := make([]mediaRange, 0, strings.Count(accept, ",")+1)
+	for _, r := range strings.Split(accept, ",") {
+		params := strings.Split(r, ";")
+		mr := mediaRange{q: 1}
+		t := strings.ToLower(strings.TrimSpace(params[0]))
+		if i := strings.IndexByte(t, '/'); i >= 0 {
+			mr.typ, mr.subtype = t[:i], t[i+1:]
+		} else {
+			mr.typ, mr.subtype = t, "*"
+		}
+		for _, p := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(p), "=", 2); len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		typ, subtype := offer, ""
+		if i := strings.IndexByte(offer, '/'); i >= 0 {
+			typ, subtype = offer[:i], offer[i+1:]
+		}
+
+		// The most specific matching range sets the quality of the offer.
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				s = 2
+			case r.typ == typ && r.subtype == "*":
+				s = 1
+			case r.typ == "*" && r.subtype == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
@@ -0,0 +1,120 @@
+package routerwithmw
+
+import (
+	"errors"
+	"html/template"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{MIMEApplicationJSON, MIMEApplicationXML, MIMETextPlain}
+	for _, tc := range []struct {
+		accept string
+		want   string
+	}{
+		{"", MIMEApplicationJSON},
+		{"*/*", MIMEApplicationJSON},
+		{"application/xml", MIMEApplicationXML},
+		{"Application/XML", MIMEApplicationXML},
+		{"text/html", ""},
+		{"application/*", MIMEApplicationJSON},
+		{"text/*", MIMETextPlain},
+		{"application/json;q=0.5, application/xml", MIMEApplicationXML},
+		{"application/json;q=0.5, application/xml;q=0.5", MIMEApplicationJSON},
+		{"application/xml;q=0.5, application/json;q=0.5", MIMEApplicationJSON},
+		{"*/*;q=0.1, text/plain", MIMETextPlain},
+		{"application/*;q=0.2, application/xml;q=0.9", MIMEApplicationXML},
+		{"application/xml;q=0.9, application/*;q=0.2", MIMEApplicationXML},
+		{"application/json;q=0, */*", MIMEApplicationXML},
+		{"application/*;q=0, text/plain;q=0.1", MIMETextPlain},
+		{"*/*;q=0", ""},
+		{"text/plain; charset=utf-8; q=0.3, application/xml; q=0.2", MIMETextPlain},
+		{"application/json;q=bogus", MIMEApplicationJSON},
+	} {
+		if got := negotiate(tc.accept, offers); got != tc.want {
+			t.Errorf("negotiate(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+	if got := negotiate("*/*", nil); got != "" {
+		t.Errorf("negotiate without offers = %q", got)
+	}
+}
+
+type renderItem struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+type renderStringer struct{ name string }
+
+func (s renderStringer) String() string { return "item " + s.name }
+
+type renderPage struct{}
+
+func (renderPage) MarshalHTML() ([]byte, error) { return []byte("<p>page</p>"), nil }
+
+func TestRender(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		value  interface{}
+		accept string
+		ctype  string
+		body   string
+	}{
+		{"struct json", renderItem{7, "hi"}, "", MIMEApplicationJSONCharsetUTF8, `{"id":7,"name":"hi"}`},
+		{"struct xml", renderItem{7, "hi"}, "application/xml", MIMEApplicationXMLCharsetUTF8, `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<renderItem><id>7</id><name>hi</name></renderItem>`},
+		{"pointer xml", &renderItem{7, "hi"}, "text/xml", MIMETextXMLCharsetUTF8, `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<renderItem><id>7</id><name>hi</name></renderItem>`},
+		{"map falls back to json", map[string]int{"a": 1}, "application/xml, application/json;q=0.5", MIMEApplicationJSONCharsetUTF8, `{"a":1}`},
+		{"slice falls back to json", []string{"a", "b"}, "application/xml, */*;q=0.1", MIMEApplicationJSONCharsetUTF8, `["a","b"]`},
+		{"string", "hello", "", MIMETextPlainCharsetUTF8, "hello"},
+		{"stringer", renderStringer{"a"}, "text/plain", MIMETextPlainCharsetUTF8, "item a"},
+		{"error", errors.New("broken"), "text/plain", MIMETextPlainCharsetUTF8, "broken"},
+		{"html", template.HTML("<b>hi</b>"), "", MIMETextHTMLCharsetUTF8, "<b>hi</b>"},
+		{"html marshaler", renderPage{}, "text/html", MIMETextHTMLCharsetUTF8, "<p>page</p>"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := new(fasthttp.RequestCtx)
+			if tc.accept != "" {
+				ctx.Request.Header.Set(HeaderAccept, tc.accept)
+			}
+			if err := Render(ctx, fasthttp.StatusOK, tc.value); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(ctx.Response.Header.ContentType()); got != tc.ctype {
+				t.Errorf("Content-Type = %q, want %q", got, tc.ctype)
+			}
+			if got := string(ctx.Response.Body()); got != tc.body {
+				t.Errorf("body = %q, want %q", got, tc.body)
+			}
+			if got := string(ctx.Response.Header.Peek(HeaderVary)); got != HeaderAccept {
+				t.Errorf("Vary = %q, want %q", got, HeaderAccept)
+			}
+		})
+	}
+}
+
+func TestRenderNotAcceptable(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		value  interface{}
+		accept string
+	}{
+		{"struct as text", renderItem{7, "hi"}, "text/plain"},
+		{"pointer as text", &renderItem{7, "hi"}, "text/plain"},
+		{"html marshaler as text", renderPage{}, "text/plain"},
+		{"struct as html", renderItem{7, "hi"}, "text/html"},
+		{"non-proto as protobuf", renderItem{7, "hi"}, MIMEApplicationProtobuf},
+		{"map as xml", map[string]int{"a": 1}, MIMEApplicationXML},
+		{"slice as xml", []string{"a", "b"}, MIMETextXML},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := new(fasthttp.RequestCtx)
+			ctx.Request.Header.Set(HeaderAccept, tc.accept)
+			if err := Render(ctx, fasthttp.StatusOK, tc.value); err != ErrNotAcceptable {
+				t.Errorf("err = %v, want ErrNotAcceptable; body %q", err, ctx.Response.Body())
+			}
+		})
+	}
+}
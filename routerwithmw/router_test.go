@@ -0,0 +1,599 @@
+package routerwithmw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func serve(r *RouterWithMW, method, uri string) *fasthttp.RequestCtx {
+	var req fasthttp.Request
+	req.Header.SetMethod(method)
+	req.SetRequestURI(uri)
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&req, nil, discardLogger{})
+	r.Handler(ctx)
+	return ctx
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Printf(string, ...interface{}) {}
+
+// trace returns a middleware recording its name, the route param "id" and the
+// matched route pattern as seen before calling next.
+func trace(name string, log *[]string) MW {
+	return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			*log = append(*log, fmt.Sprintf("%s id=%v route=%s", name, ctx.UserValue("id"), RoutePattern(ctx)))
+			next(ctx)
+		}
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var log []string
+	r := New()
+	r.Use(trace("use1", &log))
+	r.Pre(trace("pre1", &log))
+	r.Use(trace("use2", &log))
+	r.Pre(trace("pre2", &log))
+	g := r.Group("/users", trace("group", &log))
+	g.GET("/:id", func(ctx *fasthttp.RequestCtx) {
+		log = append(log, "handler")
+	}, trace("route", &log))
+
+	serve(r, "GET", "/users/42")
+
+	want := []string{
+		"pre1 id=<nil> route=",
+		"pre2 id=<nil> route=",
+		"use1 id=42 route=/users/:id",
+		"use2 id=42 route=/users/:id",
+		"group id=42 route=/users/:id",
+		"route id=42 route=/users/:id",
+		"handler",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Fatalf("middleware order:\n got %q\nwant %q", log, want)
+	}
+}
+
+func TestNestedGroupOrder(t *testing.T) {
+	var log []string
+	r := New()
+	api := r.Group("/api", trace("api", &log))
+	v1 := api.Group("/v1", trace("v1", &log))
+	api.Use(trace("late", &log))
+	v1.GET("/items/:id", func(ctx *fasthttp.RequestCtx) {})
+
+	serve(r, "GET", "/api/v1/items/7")
+
+	want := []string{
+		"api id=7 route=/api/v1/items/:id",
+		"v1 id=7 route=/api/v1/items/:id",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Fatalf("nested group order:\n got %q\nwant %q", log, want)
+	}
+}
+
+func TestPreRewritesPath(t *testing.T) {
+	r := New()
+	r.Pre(func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			if string(ctx.Path()) == "/old" {
+				ctx.URI().SetPath("/new")
+				ctx.Request.Header.SetMethod("POST")
+			}
+			next(ctx)
+		}
+	})
+	r.POST("/new", func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("new")
+	})
+
+	ctx := serve(r, "GET", "/old")
+	if got := string(ctx.Response.Body()); got != "new" {
+		t.Fatalf("body = %q, want %q", got, "new")
+	}
+}
+
+func TestFallbackMiddleware(t *testing.T) {
+	r := New()
+	r.Use(trace("use", new([]string)))
+	r.Use(func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.Set("X-Use", "1")
+			next(ctx)
+		}
+	})
+	r.UseFallback(func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			ctx.Response.Header.Set("X-Fallback", "1")
+			next(ctx)
+		}
+	})
+	r.GET("/a", func(ctx *fasthttp.RequestCtx) {})
+
+	for _, tc := range []struct {
+		method, uri string
+		status      int
+		fallback    bool
+	}{
+		{"GET", "/a", fasthttp.StatusOK, false},
+		{"GET", "/b", fasthttp.StatusNotFound, true},
+		{"POST", "/a", fasthttp.StatusMethodNotAllowed, true},
+		{"OPTIONS", "/a", fasthttp.StatusOK, true},
+		{"GET", "/a/", fasthttp.StatusMovedPermanently, true},
+	} {
+		ctx := serve(r, tc.method, tc.uri)
+		if got := ctx.Response.StatusCode(); got != tc.status {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.uri, got, tc.status)
+		}
+		if got := string(ctx.Response.Header.Peek("X-Use")); got != "1" {
+			t.Errorf("%s %s: X-Use = %q, want %q", tc.method, tc.uri, got, "1")
+		}
+		if got := string(ctx.Response.Header.Peek("X-Fallback")) == "1"; got != tc.fallback {
+			t.Errorf("%s %s: fallback middleware ran = %v, want %v", tc.method, tc.uri, got, tc.fallback)
+		}
+	}
+}
+
+func TestCustomFallbackHandlers(t *testing.T) {
+	var log []string
+	r := New()
+	r.Use(trace("use", &log))
+	r.SetNotFound(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusNotFound)
+		ctx.SetBodyString("custom 404")
+	}, trace("notfound", &log))
+	r.SetMethodNotAllowed(func(ctx *fasthttp.RequestCtx) {
+		ctx.SetStatusCode(fasthttp.StatusMethodNotAllowed)
+		ctx.SetBodyString("custom 405")
+	}, trace("notallowed", &log))
+	r.GET("/a", func(ctx *fasthttp.RequestCtx) {})
+
+	if got := string(serve(r, "GET", "/b").Response.Body()); got != "custom 404" {
+		t.Errorf("404 body = %q", got)
+	}
+	ctx := serve(r, "DELETE", "/a")
+	if got := string(ctx.Response.Body()); got != "custom 405" {
+		t.Errorf("405 body = %q", got)
+	}
+	if got := string(ctx.Response.Header.Peek(HeaderAllow)); got != "GET, OPTIONS" {
+		t.Errorf("Allow = %q", got)
+	}
+
+	want := []string{
+		"use id=<nil> route=",
+		"notfound id=<nil> route=",
+		"use id=<nil> route=",
+		"notallowed id=<nil> route=",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Fatalf("got %q, want %q", log, want)
+	}
+}
+
+func TestRouteMiddlewareIsPerRoute(t *testing.T) {
+	var log []string
+	r := New()
+	r.GET("/a", func(ctx *fasthttp.RequestCtx) {}, trace("a", &log))
+	r.GET("/b", func(ctx *fasthttp.RequestCtx) {})
+
+	serve(r, "GET", "/b")
+	serve(r, "GET", "/a")
+
+	want := []string{"a id=<nil> route=/a"}
+	if !reflect.DeepEqual(log, want) {
+		t.Fatalf("got %q, want %q", log, want)
+	}
+}
+
+func TestServeFilesUsesMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var log []string
+	r := New()
+	r.Use(trace("use", &log))
+	r.ServeFiles("/static/*filepath", dir)
+
+	ctx := serve(r, "GET", "/static/hello.txt")
+	if got := string(ctx.Response.Body()); got != "hello" {
+		t.Errorf("body = %q, want %q", got, "hello")
+	}
+	want := []string{"use id=<nil> route=/static/*filepath"}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("middleware: got %q, want %q", log, want)
+	}
+	if routes := r.Routes(); len(routes) != 1 || routes[0].Path != "/static/*filepath" {
+		t.Errorf("Routes() = %+v, want the file route", routes)
+	}
+}
+
+func TestRouteAfterFreeze(t *testing.T) {
+	var log []string
+	r := New()
+	r.Use(trace("use", &log))
+	r.Freeze()
+	r.GET("/late", func(ctx *fasthttp.RequestCtx) {})
+
+	serve(r, "GET", "/late")
+
+	want := []string{"use id=<nil> route=/late"}
+	if !reflect.DeepEqual(log, want) {
+		t.Fatalf("got %q, want %q", log, want)
+	}
+}
+
+func TestUseAfterFreezePanics(t *testing.T) {
+	r := New()
+	serve(r, "GET", "/")
+
+	for name, add := range map[string]func(){
+		"Use": func() { r.Use(func(h fasthttp.RequestHandler) fasthttp.RequestHandler { return h }) },
+		"Pre": func() { r.Pre(func(h fasthttp.RequestHandler) fasthttp.RequestHandler { return h }) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s after freeze did not panic", name)
+				}
+			}()
+			add()
+		}()
+	}
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	r := New()
+	r.HTTPErrorHandler = func(ctx *fasthttp.RequestCtx, err error) {
+		he := err.(*HTTPError)
+		ctx.SetStatusCode(he.Code)
+		ctx.SetBodyString(fmt.Sprintf("custom: %v", he.Message))
+	}
+	r.GET("/fail", func(ctx *fasthttp.RequestCtx) {
+		HandleError(ctx, NewHTTPError(fasthttp.StatusTeapot, "short and stout"))
+	})
+
+	for _, tc := range []struct {
+		method, uri string
+		status      int
+		body        string
+	}{
+		{"GET", "/fail", fasthttp.StatusTeapot, "custom: short and stout"},
+		{"GET", "/missing", fasthttp.StatusNotFound, "custom: Not Found"},
+		{"POST", "/fail", fasthttp.StatusMethodNotAllowed, "custom: Method Not Allowed"},
+	} {
+		ctx := serve(r, tc.method, tc.uri)
+		if got := ctx.Response.StatusCode(); got != tc.status {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.uri, got, tc.status)
+		}
+		if got := string(ctx.Response.Body()); got != tc.body {
+			t.Errorf("%s %s: body = %q, want %q", tc.method, tc.uri, got, tc.body)
+		}
+	}
+}
+
+func TestInternalErrorIsNotSent(t *testing.T) {
+	cause := errors.New("db: connection refused")
+	r := New()
+	r.GET("/wrapped", func(ctx *fasthttp.RequestCtx) {
+		HandleError(ctx, ErrBadRequest.WithInternal(cause))
+	})
+	r.GET("/plain", func(ctx *fasthttp.RequestCtx) {
+		HandleError(ctx, cause)
+	})
+
+	for uri, status := range map[string]int{
+		"/wrapped": fasthttp.StatusBadRequest,
+		"/plain":   fasthttp.StatusInternalServerError,
+	} {
+		ctx := serve(r, "GET", uri)
+		if got := ctx.Response.StatusCode(); got != status {
+			t.Errorf("%s: status = %d, want %d", uri, got, status)
+		}
+		if body := string(ctx.Response.Body()); strings.Contains(body, "db:") {
+			t.Errorf("%s: internal cause leaked: %q", uri, body)
+		}
+	}
+
+	err := ErrBadRequest.WithInternal(cause)
+	if !errors.Is(err, cause) || !errors.Is(err, ErrBadRequest) {
+		t.Errorf("errors.Is does not match the cause or the original error")
+	}
+	if ErrBadRequest.Inner != nil {
+		t.Errorf("WithInternal modified the shared error")
+	}
+}
+
+func TestURL(t *testing.T) {
+	h := func(ctx *fasthttp.RequestCtx) {}
+	r := New()
+	r.GET("/users/:id", h).Name = "user"
+	r.Group("/files").GET("/:owner/*path", h).Name = "file"
+	r.GET("/", h).Name = "root"
+	r.GET("/unnamed", h)
+	r.GET("/users/:id/v2", h).Name = "user"
+
+	for _, tc := range []struct {
+		name   string
+		params []interface{}
+		want   string
+	}{
+		{"user", []interface{}{42}, "/users/42"},
+		{"user", []interface{}{"a b/c"}, "/users/a%20b%2Fc"},
+		{"file", []interface{}{"joe", "docs/my cv.pdf"}, "/files/joe/docs/my%20cv.pdf"},
+		{"file", []interface{}{"joe", "/docs"}, "/files/joe/docs"},
+		{"root", nil, "/"},
+	} {
+		got, err := r.URL(tc.name, tc.params...)
+		if err != nil || got != tc.want {
+			t.Errorf("URL(%q, %v) = %q, %v; want %q", tc.name, tc.params, got, err, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		params []interface{}
+	}{
+		{"user", nil},
+		{"user", []interface{}{1, 2}},
+		{"file", []interface{}{"joe"}},
+		{"missing", nil},
+		{"", nil},
+	} {
+		if got, err := r.URL(tc.name, tc.params...); err == nil {
+			t.Errorf("URL(%q, %v) = %q, want an error", tc.name, tc.params, got)
+		}
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	h := func(ctx *fasthttp.RequestCtx) {}
+	r := New()
+	r.Pre(passthrough)
+	r.Use(passthrough)
+	r.Group("/admin", passthrough).GET("/stats", h).Name = "stats"
+	r.POST("/login", h)
+	r.GET("/debug/routes", r.RoutesHandler())
+
+	want := []RouteInfo{
+		{"GET", "/admin/stats", "stats", []string{
+			"fasthttp-mw/routerwithmw.passthrough",
+			"fasthttp-mw/routerwithmw.passthrough",
+			"fasthttp-mw/routerwithmw.passthrough",
+		}},
+		{"POST", "/login", "", []string{
+			"fasthttp-mw/routerwithmw.passthrough",
+			"fasthttp-mw/routerwithmw.passthrough",
+		}},
+	}
+	if got := r.Routes()[:2]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Routes() =\n%v\nwant\n%v", got, want)
+	}
+
+	ctx := serve(r, "GET", "/debug/routes")
+	var got []RouteInfo
+	if err := json.Unmarshal(ctx.Response.Body(), &got); err != nil || len(got) != 3 {
+		t.Fatalf("routes handler body = %s, err = %v", ctx.Response.Body(), err)
+	}
+}
+
+func passthrough(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+	return next
+}
+
+func TestHostSwitch(t *testing.T) {
+	named := func(name string) *RouterWithMW {
+		r := New()
+		r.GET("/", func(ctx *fasthttp.RequestCtx) { ctx.SetBodyString(name) })
+		return r
+	}
+	hs := NewHostSwitch()
+	hs.Host("api.example.com", named("api"))
+	hs.Host("*.example.com", named("example"))
+	hs.Host("*.tenant.example.com", named("tenant"))
+
+	for host, want := range map[string]string{
+		"api.example.com":        "api",
+		"API.Example.com:8080":   "api",
+		"www.example.com":        "example",
+		"a.tenant.example.com":   "tenant",
+		"a.b.tenant.example.com": "tenant",
+		"tenant.example.com":     "example",
+		"example.com":            "Not Found",
+		"other.org":              "Not Found",
+	} {
+		var req fasthttp.Request
+		req.SetRequestURI("http://" + host + "/")
+		ctx := new(fasthttp.RequestCtx)
+		ctx.Init(&req, nil, discardLogger{})
+		hs.Handler(ctx)
+		if got := string(ctx.Response.Body()); got != want {
+			t.Errorf("%s: body = %q, want %q", host, got, want)
+		}
+	}
+
+	hs.Default = named("default")
+	var req fasthttp.Request
+	req.SetRequestURI("http://other.org/")
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&req, nil, discardLogger{})
+	hs.Handler(ctx)
+	if got := string(ctx.Response.Body()); got != "default" {
+		t.Errorf("default: body = %q", got)
+	}
+}
+
+func TestMount(t *testing.T) {
+	var log []string
+	billing := New()
+	billing.Use(trace("billing", &log))
+	billing.GET("/invoices/:id", func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("invoice " + ctx.UserValue("id").(string))
+	})
+	billing.GET("/", func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("billing home")
+	})
+	legacy := New()
+	legacy.GET("/legacy/ping", func(ctx *fasthttp.RequestCtx) {
+		ctx.SetBodyString("pong")
+	})
+
+	r := New()
+	r.Use(trace("parent", &log))
+	r.Mount("/billing", billing, trace("mount", &log))
+	r.MountWithConfig("/legacy", legacy, MountConfig{KeepPrefix: true})
+
+	for uri, want := range map[string]string{
+		"/billing/invoices/7": "invoice 7",
+		"/billing":            "billing home",
+		"/billing/":           "billing home",
+		"/legacy/ping":        "pong",
+		"/billing/missing":    "Not Found",
+	} {
+		if got := string(serve(r, "GET", uri).Response.Body()); got != want {
+			t.Errorf("%s: body = %q, want %q", uri, got, want)
+		}
+	}
+
+	log = nil
+	serve(r, "GET", "/billing/invoices/7")
+	want := []string{
+		"parent id=<nil> route=/billing/*routerwithmw.mount",
+		"mount id=<nil> route=/billing/*routerwithmw.mount",
+		"billing id=7 route=/invoices/:id",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Fatalf("got %q, want %q", log, want)
+	}
+}
+
+func TestMountRestoresPath(t *testing.T) {
+	billing := New()
+	billing.GET("/invoices/:id", func(ctx *fasthttp.RequestCtx) {})
+
+	var after []string
+	r := New()
+	r.Use(func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+		return func(ctx *fasthttp.RequestCtx) {
+			next(ctx)
+			after = append(after, fmt.Sprintf("%s route=%s", ctx.Path(), RoutePattern(ctx)))
+		}
+	})
+	r.Mount("/billing", billing)
+
+	serve(r, "GET", "/billing/invoices/7")
+	want := []string{"/billing/invoices/7 route=/billing/*routerwithmw.mount"}
+	if !reflect.DeepEqual(after, want) {
+		t.Errorf("after next: got %q, want %q", after, want)
+	}
+}
+
+func TestMountHidesParentRoute(t *testing.T) {
+	var log []string
+	record := func(stage string) MW {
+		return func(next fasthttp.RequestHandler) fasthttp.RequestHandler {
+			return func(ctx *fasthttp.RequestCtx) {
+				log = append(log, fmt.Sprintf("%s route=%q mount=%v", stage, RoutePattern(ctx), ctx.UserValue(mountParam)))
+				next(ctx)
+			}
+		}
+	}
+	billing := New()
+	billing.Pre(record("pre"))
+	billing.UseFallback(record("fallback"))
+	billing.GET("/invoices/:id", func(ctx *fasthttp.RequestCtx) {
+		log = append(log, fmt.Sprintf("handler route=%q", RoutePattern(ctx)))
+	})
+
+	r := New()
+	r.Mount("/billing", billing)
+
+	for _, tc := range []struct {
+		path string
+		want []string
+	}{
+		{"/billing/missing", []string{`pre route="" mount=<nil>`, `fallback route="" mount=<nil>`}},
+		{"/billing/invoices/7", []string{`pre route="" mount=<nil>`, `handler route="/invoices/:id"`}},
+	} {
+		log = nil
+		serve(r, "GET", tc.path)
+		if !reflect.DeepEqual(log, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.path, log, tc.want)
+		}
+	}
+}
+
+func TestMountRedirects(t *testing.T) {
+	billing := New()
+	billing.GET("/invoices/:id", func(ctx *fasthttp.RequestCtx) {})
+	api := New()
+	api.Mount("/billing", billing)
+	r := New()
+	r.Mount("/api", api)
+
+	for uri, want := range map[string]string{
+		"http://x/api/billing/invoices/7/":    "http://x/api/billing/invoices/7",
+		"http://x/api/billing/INVOICES/7?a=b": "http://x/api/billing/invoices/7?a=b",
+	} {
+		ctx := serve(r, "GET", uri)
+		if code := ctx.Response.StatusCode(); code != fasthttp.StatusMovedPermanently {
+			t.Errorf("%s: status = %d, want 301", uri, code)
+		}
+		if got := string(ctx.Response.Header.Peek(HeaderLocation)); got != want {
+			t.Errorf("%s: Location = %q, want %q", uri, got, want)
+		}
+	}
+}
+
+func TestMountRoutesAndURL(t *testing.T) {
+	billing := New()
+	billing.Use(passthrough)
+	billing.GET("/invoices/:id", func(ctx *fasthttp.RequestCtx) {}).Name = "invoice"
+	legacy := New()
+	legacy.GET("/legacy/ping", func(ctx *fasthttp.RequestCtx) {}).Name = "ping"
+
+	r := New()
+	r.GET("/", func(ctx *fasthttp.RequestCtx) {})
+	r.Mount("/billing", billing, passthrough)
+	r.MountWithConfig("/legacy", legacy, MountConfig{KeepPrefix: true})
+
+	mw := funcName(passthrough)
+	want := []RouteInfo{
+		{Method: "GET", Path: "/", Middleware: []string{}},
+		{Method: "GET", Path: "/billing/invoices/:id", Name: "invoice", Middleware: []string{mw, mw}},
+		{Method: "GET", Path: "/legacy/ping", Name: "ping", Middleware: []string{}},
+	}
+	if got := r.Routes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Routes():\n got %+v\nwant %+v", got, want)
+	}
+
+	for name, want := range map[string]string{
+		"invoice": "/billing/invoices/7",
+		"ping":    "/legacy/ping",
+	} {
+		var params []interface{}
+		if name == "invoice" {
+			params = append(params, 7)
+		}
+		if got, err := r.URL(name, params...); err != nil || got != want {
+			t.Errorf("URL(%q) = %q, %v, want %q", name, got, err, want)
+		}
+	}
+	if got, err := r.URL(""); err == nil {
+		t.Errorf("URL(\"\") = %q, want an error", got)
+	}
+}
@@ -0,0 +1,75 @@
+package routerwithmw
+
+import (
+	"encoding/json"
+	"reflect"
+	"runtime"
+
+	"github.com/valyala/fasthttp"
+)
+
+// RouteInfo describes a registered route and the middleware chain it is
+// served through.
+type RouteInfo struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Name   string `json:"name,omitempty"`
+
+	// Middleware lists the chain from outermost to innermost: `Pre`, `Use`,
+	// group and route-level middleware. Each entry is the name of the
+	// function implementing the middleware, e.g.
+	// "fasthttp-mw/middlewares.BasicAuthWithConfig.func1".
+	Middleware []string `json:"middleware"`
+}
+
+// Routes returns the registered routes in registration order. The routes of
+// mounted routers are listed in place of their mount routes, with their full
+// path and middleware chain.
+func (r *RouterWithMW) Routes() []RouteInfo {
+	routes := make([]RouteInfo, 0, len(r.routes))
+	mounts := map[*mount]bool{}
+	for _, rt := range r.routes {
+		mws := make([]string, 0, len(r.premiddleware)+len(r.middleware)+len(rt.middleware))
+		for _, chain := range [][]MW{r.premiddleware, r.middleware, rt.middleware} {
+			for _, mw := range chain {
+				mws = append(mws, funcName(mw))
+			}
+		}
+		if rt.mount != nil {
+			if !mounts[rt.mount] {
+				mounts[rt.mount] = true
+				routes = append(routes, rt.mount.routes(mws)...)
+			}
+			continue
+		}
+		routes = append(routes, RouteInfo{
+			Method:     rt.Method,
+			Path:       rt.Path,
+			Name:       rt.Name,
+			Middleware: mws,
+		})
+	}
+	return routes
+}
+
+// RoutesHandler returns a handler serving Routes as JSON, for debugging and
+// security reviews. Register it behind authentication, it discloses the
+// router layout.
+func (r *RouterWithMW) RoutesHandler() fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) {
+		b, err := json.Marshal(r.Routes())
+		if err != nil {
+			HandleError(ctx, err)
+			return
+		}
+		ctx.SetContentType(MIMEApplicationJSONCharsetUTF8)
+		ctx.SetBody(b)
+	}
+}
+
+func funcName(f interface{}) string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return "unknown"
+}
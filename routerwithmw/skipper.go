@@ -0,0 +1,147 @@
+package routerwithmw
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/valyala/fasthttp"
+)
+
+// SkipPaths returns a Skipper skipping requests whose path matches one of
+// globs. In a glob, "*" matches any characters but "/", "?" matches one
+// character but "/", and "**" matches any characters; "**/" also matches
+// nothing, so "/api/**/health" matches "/api/health". The globs are compiled
+// once.
+func SkipPaths(globs ...string) Skipper {
+	re := compileGlobs(globs)
+	return func(c *fasthttp.RequestCtx) bool {
+		return re.Match(c.Path())
+	}
+}
+
+// SkipPrefix returns a Skipper skipping requests whose path starts with one
+// of prefixes.
+func SkipPrefix(prefixes ...string) Skipper {
+	ps := toBytes(prefixes)
+	return func(c *fasthttp.RequestCtx) bool {
+		path := c.Path()
+		for _, p := range ps {
+			if bytes.HasPrefix(path, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SkipMethods returns a Skipper skipping requests with one of methods.
+func SkipMethods(methods ...string) Skipper {
+	ms := toBytes(methods)
+	return func(c *fasthttp.RequestCtx) bool {
+		method := c.Method()
+		for _, m := range ms {
+			if bytes.Equal(method, m) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SkipHosts returns a Skipper skipping requests for one of hosts. Hosts are
+// matched case-insensitively without the port, and "*.example.com" matches
+// any subdomain of example.com. See `HostSwitch`.
+func SkipHosts(hosts ...string) Skipper {
+	var m hostMatcher[struct{}]
+	for _, h := range hosts {
+		m.add(h, struct{}{})
+	}
+	return func(c *fasthttp.RequestCtx) bool {
+		_, ok := m.match(c.Host())
+		return ok
+	}
+}
+
+// SkipIfHeader returns a Skipper skipping requests carrying the header name,
+// or, if values are given, carrying it with one of values.
+func SkipIfHeader(name string, values ...string) Skipper {
+	vs := toBytes(values)
+	return func(c *fasthttp.RequestCtx) bool {
+		v := c.Request.Header.Peek(name)
+		if len(vs) == 0 {
+			return v != nil
+		}
+		for _, want := range vs {
+			if bytes.Equal(v, want) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// And returns a Skipper skipping requests skipped by all of skippers.
+func And(skippers ...Skipper) Skipper {
+	return func(c *fasthttp.RequestCtx) bool {
+		for _, s := range skippers {
+			if !s(c) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns a Skipper skipping requests skipped by any of skippers.
+func Or(skippers ...Skipper) Skipper {
+	return func(c *fasthttp.RequestCtx) bool {
+		for _, s := range skippers {
+			if s(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a Skipper skipping the requests s processes.
+func Not(s Skipper) Skipper {
+	return func(c *fasthttp.RequestCtx) bool {
+		return !s(c)
+	}
+}
+
+// compileGlobs compiles globs into a single anchored regexp.
+func compileGlobs(globs []string) *regexp.Regexp {
+	res := make([]string, len(globs))
+	for i, g := range globs {
+		var b strings.Builder
+		for j := 0; j < len(g); j++ {
+			switch {
+			case strings.HasPrefix(g[j:], "**/"):
+				b.WriteString("(?:.*/)?")
+				j += 2
+			case strings.HasPrefix(g[j:], "**"):
+				b.WriteString(".*")
+				j++
+			case g[j] == '*':
+				b.WriteString("[^/]*")
+			case g[j] == '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(g[j : j+1]))
+			}
+		}
+		res[i] = b.String()
+	}
+	return regexp.MustCompile("^(?:" + strings.Join(res, "|") + ")$")
+}
+
+func toBytes(ss []string) [][]byte {
+	bs := make([][]byte, len(ss))
+	for i, s := range ss {
+		bs[i] = []byte(s)
+	}
+	return bs
+}
@@ -0,0 +1,129 @@
+package routerwithmw
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+func skipperCtx(method, uri string, headers ...string) *fasthttp.RequestCtx {
+	var req fasthttp.Request
+	req.Header.SetMethod(method)
+	req.SetRequestURI(uri)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&req, nil, discardLogger{})
+	return ctx
+}
+
+func TestSkipPaths(t *testing.T) {
+	for _, tc := range []struct {
+		glob, path string
+		want       bool
+	}{
+		{"/health", "/health", true},
+		{"/health", "/healthz", false},
+		{"/health", "/api/health", false},
+		{"/static/*", "/static/app.js", true},
+		{"/static/*", "/static/", true},
+		{"/static/*", "/static/js/app.js", false},
+		{"/static/*.js", "/static/app.js", true},
+		{"/static/*.js", "/static/app.css", false},
+		{"/v?/users", "/v1/users", true},
+		{"/v?/users", "/v10/users", false},
+		{"/v?/users", "/v//users", false},
+		{"/static/**", "/static/js/app.js", true},
+		{"/static/**", "/static/", true},
+		{"/api/**/health", "/api/health", true},
+		{"/api/**/health", "/api/v1/health", true},
+		{"/api/**/health", "/api/v1/internal/health", true},
+		{"/api/**/health", "/api/v1/healthz", false},
+		{"/api/**/health", "/apihealth", false},
+		{"/a.b", "/axb", false},
+		{"/a+", "/a+", true},
+		{"/a+", "/aa", false},
+	} {
+		if got := SkipPaths(tc.glob)(skipperCtx("GET", "http://x"+tc.path)); got != tc.want {
+			t.Errorf("SkipPaths(%q)(%q) = %v, want %v", tc.glob, tc.path, got, tc.want)
+		}
+	}
+
+	s := SkipPaths("/health", "/metrics/*")
+	for path, want := range map[string]bool{"/health": true, "/metrics/cpu": true, "/users": false} {
+		if got := s(skipperCtx("GET", "http://x"+path)); got != want {
+			t.Errorf("SkipPaths(...)(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestSkippers(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		s    Skipper
+		ctx  *fasthttp.RequestCtx
+		want bool
+	}{
+		{"prefix", SkipPrefix("/internal", "/debug"), skipperCtx("GET", "http://x/debug/pprof"), true},
+		{"prefix miss", SkipPrefix("/internal"), skipperCtx("GET", "http://x/api/internal"), false},
+		{"method", SkipMethods("OPTIONS", "HEAD"), skipperCtx("HEAD", "http://x/"), true},
+		{"method miss", SkipMethods("OPTIONS"), skipperCtx("GET", "http://x/"), false},
+		{"host", SkipHosts("admin.example.com"), skipperCtx("GET", "http://Admin.Example.com:8080/"), true},
+		{"host wildcard", SkipHosts("*.example.com"), skipperCtx("GET", "http://a.b.example.com/"), true},
+		{"host wildcard bare domain", SkipHosts("*.example.com"), skipperCtx("GET", "http://example.com/"), false},
+		{"host miss", SkipHosts("admin.example.com"), skipperCtx("GET", "http://example.com/"), false},
+		{"header present", SkipIfHeader("X-Internal"), skipperCtx("GET", "http://x/", "X-Internal", ""), true},
+		{"header absent", SkipIfHeader("X-Internal"), skipperCtx("GET", "http://x/"), false},
+		{"header value", SkipIfHeader("Upgrade", "websocket"), skipperCtx("GET", "http://x/", "Upgrade", "websocket"), true},
+		{"header other value", SkipIfHeader("Upgrade", "websocket"), skipperCtx("GET", "http://x/", "Upgrade", "h2c"), false},
+	} {
+		if got := tc.s(tc.ctx); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSkipperCombinators(t *testing.T) {
+	yes := func(*fasthttp.RequestCtx) bool { return true }
+	no := DefaultSkipper
+	ctx := skipperCtx("GET", "http://x/")
+
+	for _, tc := range []struct {
+		name string
+		s    Skipper
+		want bool
+	}{
+		{"And()", And(), true},
+		{"And(yes, yes)", And(yes, yes), true},
+		{"And(yes, no)", And(yes, no), false},
+		{"Or()", Or(), false},
+		{"Or(no, yes)", Or(no, yes), true},
+		{"Or(no, no)", Or(no, no), false},
+		{"Not(yes)", Not(yes), false},
+		{"Not(no)", Not(no), true},
+		{"And(Or(no, yes), Not(no))", And(Or(no, yes), Not(no)), true},
+		{"health checks from outside", And(SkipPaths("/health"), Not(SkipHosts("localhost"))), false},
+	} {
+		if got := tc.s(ctx); got != tc.want {
+			t.Errorf("%s = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSkippersDoNotAllocate(t *testing.T) {
+	s := Or(
+		SkipPaths("/api/**/health", "/static/*"),
+		SkipPrefix("/debug"),
+		SkipMethods("OPTIONS"),
+		SkipHosts("admin.example.com", "*.internal.example.com"),
+		Not(SkipIfHeader("Authorization")),
+	)
+	ctx := skipperCtx("GET", "http://app.example.com:8080/api/users", "Authorization", "Bearer x")
+	if s(ctx) {
+		t.Fatal("request skipped")
+	}
+	if n := testing.AllocsPerRun(100, func() { s(ctx) }); n != 0 {
+		t.Errorf("skippers allocate %v times per request, want 0", n)
+	}
+}
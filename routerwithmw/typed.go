@@ -0,0 +1,58 @@
+package routerwithmw
+
+import (
+	"reflect"
+
+	"github.com/valyala/fasthttp"
+)
+
+// StatusCoder is implemented by responses choosing their own status code.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Typed adapts a typed handler function to a fasthttp.RequestHandler.
+//
+// The request is bound into a new Req with `Bind()`; if Req is a pointer type
+// a new value is allocated. It is then checked with `Validate()`, failures
+// becoming "422 - Unprocessable Entity". The Resp returned by fn is sent with
+// `Render()`, with status "200 - OK" unless it implements StatusCoder;
+// "204 - No Content" sends no body. Errors from any step go to the
+// centralized HTTPErrorHandler.
+func Typed[Req, Resp any](fn func(ctx *fasthttp.RequestCtx, req Req) (Resp, error)) fasthttp.RequestHandler {
+	return FromHandlerFunc(func(c *fasthttp.RequestCtx) error {
+		req, err := bindTyped[Req](c)
+		if err != nil {
+			return err
+		}
+		resp, err := fn(c, req)
+		if err != nil {
+			return err
+		}
+
+		code := fasthttp.StatusOK
+		if sc, ok := any(resp).(StatusCoder); ok {
+			code = sc.StatusCode()
+		}
+		if code == fasthttp.StatusNoContent {
+			c.SetStatusCode(code)
+			return nil
+		}
+		return Render(c, code, resp)
+	})
+}
+
+func bindTyped[Req any](c *fasthttp.RequestCtx) (req Req, err error) {
+	target := any(&req)
+	if t := reflect.TypeOf(req); t == nil {
+		return
+	} else if t.Kind() == reflect.Ptr {
+		req = reflect.New(t.Elem()).Interface().(Req)
+		target = req
+	}
+	if err = Bind(c, target); err != nil {
+		return
+	}
+	err = Validate(c, target)
+	return
+}
@@ -0,0 +1,97 @@
+package routerwithmw
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type typedReq struct {
+	ID   int    `param:"id"`
+	Name string `json:"name" validate:"required"`
+}
+
+type typedResp struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	code int
+}
+
+func (r typedResp) StatusCode() int { return r.code }
+
+func serveTyped(t *testing.T, h fasthttp.RequestHandler, body string) *fasthttp.RequestCtx {
+	t.Helper()
+	r := New()
+	r.POST("/items/:id", h)
+
+	var req fasthttp.Request
+	req.Header.SetMethod("POST")
+	req.SetRequestURI("/items/7")
+	if body != "" {
+		req.Header.SetContentType(MIMEApplicationJSON)
+		req.SetBodyString(body)
+	}
+	ctx := new(fasthttp.RequestCtx)
+	ctx.Init(&req, nil, discardLogger{})
+	r.Handler(ctx)
+	return ctx
+}
+
+func TestTyped(t *testing.T) {
+	echo := func(id int, name string, code int) (typedResp, error) {
+		return typedResp{ID: id, Name: name, code: code}, nil
+	}
+
+	for _, tc := range []struct {
+		name   string
+		h      fasthttp.RequestHandler
+		body   string
+		status int
+		resp   string
+	}{
+		{"pointer request", Typed(func(c *fasthttp.RequestCtx, req *typedReq) (typedResp, error) {
+			return echo(req.ID, req.Name, fasthttp.StatusOK)
+		}), `{"name":"ann"}`, fasthttp.StatusOK, `{"id":7,"name":"ann"}`},
+		{"value request", Typed(func(c *fasthttp.RequestCtx, req typedReq) (typedResp, error) {
+			return echo(req.ID, req.Name, fasthttp.StatusOK)
+		}), `{"name":"ann"}`, fasthttp.StatusOK, `{"id":7,"name":"ann"}`},
+		{"interface request", Typed(func(c *fasthttp.RequestCtx, req interface{}) (typedResp, error) {
+			if req != nil {
+				t.Errorf("interface request = %#v, want nil", req)
+			}
+			return echo(0, "none", fasthttp.StatusOK)
+		}), "", fasthttp.StatusOK, `{"id":0,"name":"none"}`},
+		{"status coder", Typed(func(c *fasthttp.RequestCtx, req *typedReq) (typedResp, error) {
+			return echo(req.ID, req.Name, fasthttp.StatusCreated)
+		}), `{"name":"ann"}`, fasthttp.StatusCreated, `{"id":7,"name":"ann"}`},
+		{"no content", Typed(func(c *fasthttp.RequestCtx, req *typedReq) (typedResp, error) {
+			return echo(req.ID, req.Name, fasthttp.StatusNoContent)
+		}), `{"name":"ann"}`, fasthttp.StatusNoContent, ""},
+		{"plain response", Typed(func(c *fasthttp.RequestCtx, req *typedReq) (string, error) {
+			return "hello " + req.Name, nil
+		}), `{"name":"ann"}`, fasthttp.StatusOK, "hello ann"},
+		{"bind error", Typed(func(c *fasthttp.RequestCtx, req *typedReq) (typedResp, error) {
+			t.Error("fn called after a bind error")
+			return typedResp{}, nil
+		}), `{"name":`, fasthttp.StatusBadRequest, ""},
+		{"validation error", Typed(func(c *fasthttp.RequestCtx, req *typedReq) (typedResp, error) {
+			t.Error("fn called after a validation error")
+			return typedResp{}, nil
+		}), `{}`, fasthttp.StatusUnprocessableEntity, ""},
+		{"handler error", Typed(func(c *fasthttp.RequestCtx, req *typedReq) (typedResp, error) {
+			return typedResp{}, ErrForbidden
+		}), `{"name":"ann"}`, fasthttp.StatusForbidden, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := serveTyped(t, tc.h, tc.body)
+			if ctx.Response.StatusCode() != tc.status {
+				t.Fatalf("status = %d, want %d: %s", ctx.Response.StatusCode(), tc.status, ctx.Response.Body())
+			}
+			if tc.status < 400 {
+				if got := string(ctx.Response.Body()); got != tc.resp {
+					t.Errorf("body = %q, want %q", got, tc.resp)
+				}
+			}
+		})
+	}
+}
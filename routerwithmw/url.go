@@ -0,0 +1,83 @@
+package routerwithmw
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URL builds the path of the route named name, filling its `:param` and
+// `*catchall` segments in order with params. Param values are escaped as
+// single path segments; a catch-all value may span several segments and may
+// be given with or without its leading slash.
+//
+// Routes of mounted routers are looked up after the routes of r, and their
+// path includes the mount prefix. When several routes share a name, the first
+// one registered wins.
+//
+// It returns an error if no route is named name, name is empty, or if the
+// number of params does not match the route pattern.
+func (r *RouterWithMW) URL(name string, params ...interface{}) (string, error) {
+	if rt := r.namedRoute(name); rt != nil {
+		return reversePath(rt.Path, params)
+	}
+	for _, rt := range r.routes {
+		if m := rt.mount; m != nil && m.sub.namedRoute(name) != nil {
+			path, err := m.sub.URL(name, params...)
+			if err != nil || m.config.KeepPrefix {
+				return path, err
+			}
+			return m.prefix + path, nil
+		}
+	}
+	return "", fmt.Errorf("routerwithmw: no route named %q", name)
+}
+
+func (r *RouterWithMW) namedRoute(name string) *Route {
+	if name == "" {
+		return nil
+	}
+	for _, rt := range r.routes {
+		if rt.Name == name {
+			return rt
+		}
+	}
+	return nil
+}
+
+func reversePath(pattern string, params []interface{}) (string, error) {
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != ':' && c != '*' {
+			b.WriteByte(c)
+			continue
+		}
+
+		end := i + 1
+		for end < len(pattern) && pattern[end] != '/' {
+			end++
+		}
+		if n == len(params) {
+			return "", fmt.Errorf("routerwithmw: missing value for param %q of route %q", pattern[i+1:end], pattern)
+		}
+		v := fmt.Sprint(params[n])
+		n++
+
+		if c == ':' {
+			b.WriteString(url.PathEscape(v))
+		} else {
+			segments := strings.Split(strings.TrimPrefix(v, "/"), "/")
+			for j, s := range segments {
+				segments[j] = url.PathEscape(s)
+			}
+			b.WriteString(strings.Join(segments, "/"))
+		}
+		i = end - 1
+	}
+	if n != len(params) {
+		return "", fmt.Errorf("routerwithmw: route %q takes %d params, got %d", pattern, n, len(params))
+	}
+	return b.String(), nil
+}
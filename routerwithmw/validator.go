@@ -0,0 +1,346 @@
+package routerwithmw
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/valyala/fasthttp"
+)
+
+type (
+	// Validator is the interface that wraps the Validate method.
+	Validator interface {
+		Validate(i interface{}) error
+	}
+
+	// TagValidator validates structs according to the `validate` tag of their
+	// fields, a comma-separated list of rules:
+	//
+	// - "required" - the value is not the zero value
+	// - "omitempty" - skip the other rules when the value is the zero value
+	// - "min=<n>", "max=<n>" - bounds for numbers, or for the length of
+	//   strings, slices and maps
+	// - "len=<n>" - exact length of strings, slices and maps
+	// - "oneof=<a> <b> ..." - the value is one of the space-separated values
+	// - "email" - the string is an email address
+	// - "regex=<re>" - the string matches re; must be the last rule as re
+	//   may contain commas
+	//
+	// Fields are named after their `json` tag when present. Nested structs are
+	// validated with their fields named "<parent>.<field>". All failed rules
+	// are reported together as ValidationErrors.
+	TagValidator struct {
+		types sync.Map // reflect.Type -> []fieldRules
+	}
+
+	// FieldError describes a failed validation rule.
+	FieldError struct {
+		Field   string `json:"field" xml:"field"`
+		Rule    string `json:"rule" xml:"rule"`
+		Message string `json:"message" xml:"message"`
+	}
+
+	// ValidationErrors is the error returned by TagValidator.
+	ValidationErrors []FieldError
+
+	// typeRules are the compiled rules of a struct type, or the error
+	// compiling its tags.
+	typeRules struct {
+		fields []fieldRules
+		err    error
+	}
+
+	// tagError is an invalid `validate` tag, a programming error rather than
+	// a validation failure.
+	tagError struct {
+		err error
+	}
+
+	fieldRules struct {
+		index  []int
+		name   string
+		nested bool
+		rules  []rule
+	}
+
+	rule struct {
+		name, param string
+		omitempty   bool
+		check       func(v reflect.Value) bool
+		message     string
+	}
+)
+
+func (te *tagError) Error() string {
+	return te.err.Error()
+}
+
+func (te *tagError) Unwrap() error {
+	return te.err
+}
+
+// Error makes it compatible with `error` interface.
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, fe := range ve {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate validates i with the Validator of the router serving c, or with a
+// TagValidator outside of a router, then with i's own `Validate() error`
+// method if it has one. Failures are returned as "422 - Unprocessable
+// Entity"; ValidationErrors are listed in the message and in the "errors"
+// problem details extension member. An invalid `validate` tag is a bug and
+// is returned as "500 - Internal Server Error" with the tag error as internal
+// error.
+func Validate(c *fasthttp.RequestCtx, i interface{}) error {
+	var v Validator = defaultValidator
+	if r := routerOf(c); r != nil && r.Validator != nil {
+		v = r.Validator
+	}
+	err := v.Validate(i)
+	if sv, ok := i.(interface{ Validate() error }); ok && err == nil {
+		err = sv.Validate()
+	}
+	if err == nil {
+		return nil
+	}
+
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return err
+	}
+	var te *tagError
+	if errors.As(err, &te) {
+		return ErrInternalServerError.WithInternal(err)
+	}
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		he = NewHTTPError(fasthttp.StatusUnprocessableEntity, ve)
+		he.Extensions = map[string]interface{}{"errors": []FieldError(ve)}
+		return he
+	}
+	return NewHTTPError(fasthttp.StatusUnprocessableEntity, err.Error()).WithInternal(err)
+}
+
+// Validate validates i with Validator, see `Validate()`.
+func (c *Context) Validate(i interface{}) error {
+	return Validate(c.RequestCtx, i)
+}
+
+var defaultValidator = &TagValidator{}
+
+// Validate validates the struct i, or the struct it points to. Other values
+// are valid.
+func (tv *TagValidator) Validate(i interface{}) error {
+	v := reflect.ValueOf(i)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var ve ValidationErrors
+	if err := tv.validate(v, "", &ve); err != nil {
+		return err
+	}
+	if len(ve) > 0 {
+		return ve
+	}
+	return nil
+}
+
+func (tv *TagValidator) validate(v reflect.Value, prefix string, ve *ValidationErrors) error {
+	fields, err := tv.rulesOf(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		fv := v.FieldByIndex(f.index)
+		for _, r := range f.rules {
+			if r.name == "required" {
+				if fv.IsZero() {
+					*ve = append(*ve, FieldError{prefix + f.name, r.name, r.message})
+					break
+				}
+				continue
+			}
+			if r.omitempty && fv.IsZero() {
+				break
+			}
+			ev := fv
+			for ev.Kind() == reflect.Ptr && !ev.IsNil() {
+				ev = ev.Elem()
+			}
+			if ev.Kind() == reflect.Ptr {
+				break
+			}
+			if !r.check(ev) {
+				*ve = append(*ve, FieldError{prefix + f.name, r.name, r.message})
+			}
+		}
+
+		if f.nested {
+			for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				if err := tv.validate(fv, prefix+f.name+".", ve); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// rulesOf returns the compiled rules of the fields of struct type t. Both
+// the rules and the error of an invalid tag are cached.
+func (tv *TagValidator) rulesOf(t reflect.Type) ([]fieldRules, error) {
+	if tr, ok := tv.types.Load(t); ok {
+		return tr.(typeRules).fields, tr.(typeRules).err
+	}
+
+	var fields []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		f := fieldRules{index: sf.Index, name: sf.Name}
+		if name := strings.Split(sf.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			f.name = name
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		f.nested = ft.Kind() == reflect.Struct
+
+		rules, err := compileRules(sf.Tag.Get("validate"))
+		if err != nil {
+			err = &tagError{fmt.Errorf("routerwithmw: field %s.%s: %v", t.Name(), sf.Name, err)}
+			tv.types.Store(t, typeRules{err: err})
+			return nil, err
+		}
+		f.rules = rules
+		if len(f.rules) > 0 || f.nested {
+			fields = append(fields, f)
+		}
+	}
+	tv.types.Store(t, typeRules{fields: fields})
+	return fields, nil
+}
+
+func compileRules(tag string) ([]rule, error) {
+	var (
+		rules     []rule
+		omitempty bool
+	)
+	for tag != "" {
+		var s string
+		if strings.HasPrefix(tag, "regex=") {
+			s, tag = tag, ""
+		} else if i := strings.IndexByte(tag, ','); i >= 0 {
+			s, tag = tag[:i], tag[i+1:]
+		} else {
+			s, tag = tag, ""
+		}
+
+		r := rule{name: s}
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			r.name, r.param = s[:i], s[i+1:]
+		}
+		switch r.name {
+		case "omitempty":
+			omitempty = true
+			continue
+		case "required":
+			r.message = "is required"
+		case "min", "max", "len":
+			n, err := strconv.ParseFloat(r.param, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s rule %q", r.name, s)
+			}
+			r.check, r.message = sizeCheck(r.name, n)
+		case "oneof":
+			values := strings.Fields(r.param)
+			r.check = func(v reflect.Value) bool {
+				s := fmt.Sprint(v.Interface())
+				for _, o := range values {
+					if s == o {
+						return true
+					}
+				}
+				return false
+			}
+			r.message = "must be one of [" + strings.Join(values, " ") + "]"
+		case "email":
+			r.check = func(v reflect.Value) bool {
+				if v.Kind() != reflect.String {
+					return false
+				}
+				a, err := mail.ParseAddress(v.String())
+				return err == nil && a.Address == v.String()
+			}
+			r.message = "must be a valid email address"
+		case "regex":
+			re, err := regexp.Compile(r.param)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex rule %q: %v", s, err)
+			}
+			r.check = func(v reflect.Value) bool {
+				return v.Kind() == reflect.String && re.MatchString(v.String())
+			}
+			r.message = "must match " + r.param
+		default:
+			return nil, fmt.Errorf("unknown validation rule %q", r.name)
+		}
+		rules = append(rules, r)
+	}
+	for i := range rules {
+		rules[i].omitempty = omitempty
+	}
+	return rules, nil
+}
+
+// sizeCheck returns the check and message of a min, max or len rule.
+func sizeCheck(name string, n float64) (func(reflect.Value) bool, string) {
+	cmp := map[string]func(float64) bool{
+		"min": func(x float64) bool { return x >= n },
+		"max": func(x float64) bool { return x <= n },
+		"len": func(x float64) bool { return x == n },
+	}[name]
+	check := func(v reflect.Value) bool {
+		switch v.Kind() {
+		case reflect.String:
+			return cmp(float64(utf8.RuneCountInString(v.String())))
+		case reflect.Slice, reflect.Map, reflect.Array:
+			return cmp(float64(v.Len()))
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return name != "len" && cmp(float64(v.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return name != "len" && cmp(float64(v.Uint()))
+		case reflect.Float32, reflect.Float64:
+			return name != "len" && cmp(v.Float())
+		}
+		return false
+	}
+
+	bound := strconv.FormatFloat(n, 'f', -1, 64)
+	message := map[string]string{
+		"min": "must be at least " + bound,
+		"max": "must be at most " + bound,
+		"len": "must have length " + bound,
+	}[name]
+	return check, message
+}
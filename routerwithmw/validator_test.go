@@ -0,0 +1,181 @@
+package routerwithmw
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+type validAddress struct {
+	City string `json:"city" validate:"required"`
+	Zip  string `validate:"len=5"`
+}
+
+type validUser struct {
+	Name    string            `json:"name" validate:"required,min=2,max=5"`
+	Nick    string            `json:"nick" validate:"omitempty,min=3"`
+	Age     int               `json:"age" validate:"min=18,max=99"`
+	Score   *float64          `json:"score" validate:"omitempty,max=1.5"`
+	Tags    []string          `json:"tags" validate:"min=1,max=2"`
+	Code    string            `json:"code" validate:"omitempty,len=3"`
+	Role    string            `json:"role" validate:"oneof=admin user"`
+	Email   string            `json:"email" validate:"omitempty,email"`
+	Pattern string            `json:"pattern" validate:"omitempty,regex=^a{1,2}$"`
+	Address validAddress      `json:"address"`
+	Home    *validAddress     `json:"home"`
+	Labels  map[string]string `validate:"omitempty,max=1"`
+}
+
+func validUserOK() validUser {
+	return validUser{
+		Name:    "ann",
+		Age:     30,
+		Tags:    []string{"a"},
+		Role:    "user",
+		Address: validAddress{City: "Oslo", Zip: "01234"},
+	}
+}
+
+func TestTagValidator(t *testing.T) {
+	score := 2.0
+	for _, tc := range []struct {
+		name   string
+		modify func(u *validUser)
+		want   ValidationErrors
+	}{
+		{"valid", func(u *validUser) {}, nil},
+		{"required", func(u *validUser) { u.Name = "" },
+			ValidationErrors{{"name", "required", "is required"}}},
+		{"omitempty skips the zero value", func(u *validUser) { u.Nick = "" }, nil},
+		{"omitempty checks other values", func(u *validUser) { u.Nick = "ab" },
+			ValidationErrors{{"nick", "min", "must be at least 3"}}},
+		{"string min counts runes", func(u *validUser) { u.Name = "é" },
+			ValidationErrors{{"name", "min", "must be at least 2"}}},
+		{"string max", func(u *validUser) { u.Name = "annabel" },
+			ValidationErrors{{"name", "max", "must be at most 5"}}},
+		{"string len", func(u *validUser) { u.Code = "ab" },
+			ValidationErrors{{"code", "len", "must have length 3"}}},
+		{"number min", func(u *validUser) { u.Age = 17 },
+			ValidationErrors{{"age", "min", "must be at least 18"}}},
+		{"number max", func(u *validUser) { u.Age = 100 },
+			ValidationErrors{{"age", "max", "must be at most 99"}}},
+		{"pointer to number", func(u *validUser) { u.Score = &score },
+			ValidationErrors{{"score", "max", "must be at most 1.5"}}},
+		{"slice min", func(u *validUser) { u.Tags = nil },
+			ValidationErrors{{"tags", "min", "must be at least 1"}}},
+		{"slice max", func(u *validUser) { u.Tags = []string{"a", "b", "c"} },
+			ValidationErrors{{"tags", "max", "must be at most 2"}}},
+		{"map max", func(u *validUser) { u.Labels = map[string]string{"a": "", "b": ""} },
+			ValidationErrors{{"Labels", "max", "must be at most 1"}}},
+		{"oneof", func(u *validUser) { u.Role = "root" },
+			ValidationErrors{{"role", "oneof", "must be one of [admin user]"}}},
+		{"email", func(u *validUser) { u.Email = "Ann <ann@example.com>" },
+			ValidationErrors{{"email", "email", "must be a valid email address"}}},
+		{"valid email", func(u *validUser) { u.Email = "ann@example.com" }, nil},
+		{"regex with comma", func(u *validUser) { u.Pattern = "aaa" },
+			ValidationErrors{{"pattern", "regex", "must match ^a{1,2}$"}}},
+		{"regex match", func(u *validUser) { u.Pattern = "aa" }, nil},
+		{"nested struct", func(u *validUser) { u.Address = validAddress{Zip: "1"} },
+			ValidationErrors{
+				{"address.city", "required", "is required"},
+				{"address.Zip", "len", "must have length 5"},
+			}},
+		{"nested pointer", func(u *validUser) { u.Home = &validAddress{Zip: "01234"} },
+			ValidationErrors{{"home.city", "required", "is required"}}},
+		{"all failures", func(u *validUser) { u.Name, u.Age = "", 1 },
+			ValidationErrors{
+				{"name", "required", "is required"},
+				{"age", "min", "must be at least 18"},
+			}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			u := validUserOK()
+			tc.modify(&u)
+			err := (&TagValidator{}).Validate(&u)
+			if tc.want == nil {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var ve ValidationErrors
+			if !errors.As(err, &ve) {
+				t.Fatalf("Validate() = %v, want ValidationErrors", err)
+			}
+			if !reflect.DeepEqual(ve, tc.want) {
+				t.Errorf("Validate() = %#v, want %#v", ve, tc.want)
+			}
+		})
+	}
+}
+
+func TestTagValidatorNonStruct(t *testing.T) {
+	for _, i := range []interface{}{nil, 1, "a", (*validUser)(nil), []validUser{{}}} {
+		if err := (&TagValidator{}).Validate(i); err != nil {
+			t.Errorf("Validate(%#v) = %v, want nil", i, err)
+		}
+	}
+}
+
+type validSelf struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func (v validSelf) Validate() error {
+	if v.Name == "root" {
+		return errors.New("name is reserved")
+	}
+	return nil
+}
+
+type validTypo struct {
+	Name string `json:"name" validate:"requird"`
+}
+
+func TestValidate(t *testing.T) {
+	ctx := new(fasthttp.RequestCtx)
+
+	err := Validate(ctx, &validSelf{})
+	var he *HTTPError
+	if !errors.As(err, &he) || he.Code != fasthttp.StatusUnprocessableEntity {
+		t.Fatalf("Validate() = %v, want 422", err)
+	}
+	want := []FieldError{{"name", "required", "is required"}}
+	if got := he.Extensions["errors"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("errors extension = %#v, want %#v", got, want)
+	}
+
+	err = Validate(ctx, &validSelf{Name: "root"})
+	if !errors.As(err, &he) || he.Code != fasthttp.StatusUnprocessableEntity || he.Message != "name is reserved" {
+		t.Errorf("Validate() = %v, want 422 from the Validate method", err)
+	}
+
+	if err := Validate(ctx, &validSelf{Name: "ann"}); err != nil {
+		t.Errorf("Validate() = %v, want nil", err)
+	}
+}
+
+func TestValidateInvalidTag(t *testing.T) {
+	tv := &TagValidator{}
+	for i := 0; i < 2; i++ {
+		err := tv.Validate(&validTypo{})
+		var te *tagError
+		if !errors.As(err, &te) {
+			t.Fatalf("Validate() #%d = %v, want a tag error", i, err)
+		}
+	}
+	if _, ok := tv.types.Load(reflect.TypeOf(validTypo{})); !ok {
+		t.Error("invalid type not cached")
+	}
+
+	err := Validate(new(fasthttp.RequestCtx), &validTypo{})
+	var he *HTTPError
+	if !errors.As(err, &he) || he.Code != fasthttp.StatusInternalServerError {
+		t.Fatalf("Validate() = %v, want 500", err)
+	}
+	if he.Message != ErrInternalServerError.Message || he.Inner == nil {
+		t.Errorf("Validate() = %#v, want the tag error as internal error only", he)
+	}
+}
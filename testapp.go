@@ -3,7 +3,6 @@ package main
 import (
 	"fmt"
 	//fastrouter "github.com/buaazp/fasthttprouter"
-	"bytes"
 	"encoding/json"
 	"fasthttp-mw/middlewares"
 	"fasthttp-mw/routerwithmw"
@@ -33,7 +32,10 @@ func main() {
 	postHandler := func(ctx *fasthttp.RequestCtx) {
 		var v *Resp = new(Resp)
 		var u Resp
-		ctx.Request.BodyWriteTo(v)
+		if err := routerwithmw.Bind(ctx, v); err != nil {
+			routerwithmw.HandleError(ctx, err)
+			return
+		}
 		fmt.Println(v)
 		jsval, _ := json.Marshal(*v)
 		fmt.Println(jsval)
@@ -67,36 +69,9 @@ func main() {
 	}
 }
 
-//write body to struct example
+//bind body to struct example
 
 type Resp struct {
-	A string `json:"a"`
-	B string `json:"b"`
-}
-
-func (r *Resp) Write(p []byte) (n int, err error) {
-	//lines(p), with some light trimming
-	fields := bytes.Split(bytes.Trim(p, " \n"), []byte("\n"))
-	for _, field := range fields {
-		//nil check
-		if bytes.Compare(field, nil) == 0 {
-			continue
-		}
-		//split each line to a k-v pair
-		kv := bytes.SplitN(field, []byte{':'}, 2)
-		if kv == nil {
-			continue
-		}
-		k := bytes.Trim(kv[0], " ")
-		v := bytes.Trim(kv[1], " ")
-		//writing to struct
-		switch string(bytes.ToLower(k)) {
-		case "a":
-			r.A = string(v)
-		case "b":
-			r.B = string(v)
-		}
-	}
-
-	return 0, nil
+	A string `json:"a" xml:"a" form:"a" query:"a"`
+	B string `json:"b" xml:"b" form:"b" query:"b"`
 }